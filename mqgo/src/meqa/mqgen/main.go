@@ -29,13 +29,21 @@ func main() {
 	swaggerFile := flag.String("s", swaggerJSONFile, "the swagger.yml file location")
 	algorithm := flag.String("a", "all", "the algorithm - simple, object, path, all")
 	verbose := flag.Bool("v", false, "turn on verbose mode")
+	readOnly := flag.Bool("read-only", false, "only generate tests for safe (get/head) operations")
+	includeDeprecated := flag.Bool("include-deprecated", false, "also generate tests for deprecated operations")
+	flag.Var(mqswag.TagRules, "match-rule", "take the parameter's value from the given object field, as param=Class.property, can be repeated")
+	flag.StringVar(&mqswag.SpecPatch, "spec-patch", "", "a JSON Patch or JSON Merge Patch file applied to the swagger spec before generating")
+	exactMatch := flag.Bool("exact-match", false, "ignore the parameter tags whose Class.property doesn't match the parameter name exactly")
 
 	flag.Parse()
-	run(meqaPath, swaggerFile, algorithm, verbose)
+	mqswag.ExactTagMatch = *exactMatch
+	run(meqaPath, swaggerFile, algorithm, verbose, readOnly, includeDeprecated)
 }
 
-func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool) {
-	mqutil.Verbose = *verbose
+func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool, readOnly *bool, includeDeprecated *bool) {
+	if *verbose {
+		mqutil.LogLevel = mqutil.LevelDebug
+	}
 
 	swaggerJsonPath := *swaggerFile
 	if fi, err := os.Stat(swaggerJsonPath); os.IsNotExist(err) || fi.Mode().IsDir() {
@@ -57,13 +65,13 @@ func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool
 	// loading swagger.json
 	swagger, err := mqswag.CreateSwaggerFromURL(swaggerJsonPath, *meqaPath)
 	if err != nil {
-		mqutil.Logger.Printf("Error: %s", err.Error())
+		mqutil.Errorf("%s", err.Error())
 		os.Exit(1)
 	}
 	dag := mqswag.NewDAG()
 	err = swagger.AddToDAG(dag)
 	if err != nil {
-		mqutil.Logger.Printf("Error: %s", err.Error())
+		mqutil.Errorf("%s", err.Error())
 		os.Exit(1)
 	}
 
@@ -88,13 +96,22 @@ func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool
 			testPlan, err = mqplan.GenerateSimpleTestPlan(swagger, dag)
 		}
 		if err != nil {
-			mqutil.Logger.Printf("Error: %s", err.Error())
+			mqutil.Errorf("%s", err.Error())
 			os.Exit(1)
 		}
+		if !*includeDeprecated {
+			count := testPlan.FilterDeprecated()
+			if count > 0 {
+				fmt.Printf("Skipped %d tests of deprecated operations, use -include-deprecated to keep them\n", count)
+			}
+		}
+		if *readOnly {
+			testPlan.FilterReadOnly()
+		}
 		testPlanFile := filepath.Join(testPlanPath, algo+".yml")
 		err = testPlan.DumpToFile(testPlanFile)
 		if err != nil {
-			mqutil.Logger.Printf("Error: %s", err.Error())
+			mqutil.Errorf("%s", err.Error())
 			os.Exit(1)
 		}
 		fmt.Println("Test plans generated at:", testPlanFile)
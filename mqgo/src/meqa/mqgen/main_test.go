@@ -14,7 +14,9 @@ func TestMqgen(t *testing.T) {
 	swaggerPath := filepath.Join(meqaPath, "swagger_meqa.yml")
 	algorithm := "all"
 	verbose := false
-	run(&meqaPath, &swaggerPath, &algorithm, &verbose)
+	readOnly := false
+	includeDeprecated := false
+	run(&meqaPath, &swaggerPath, &algorithm, &verbose, &readOnly, &includeDeprecated)
 }
 
 func TestMain(m *testing.M) {
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestMqgo(t *testing.T) {
@@ -18,9 +19,17 @@ func TestMqgo(t *testing.T) {
 	password := ""
 	apitoken := ""
 	verbose := false
+	selftest := false
+	waitForReady := false
+	healthURL := ""
+	readyTimeout := time.Minute
+	retryRun := 0
+	retryThreshold := 0.5
+	strict := false
 
 	mqutil.Logger = mqutil.NewFileLogger(filepath.Join(meqaPath, "mqgo.log"))
-	runMeqa(&meqaPath, &swaggerPath, &planPath, &resultPath, &testToRun, &username, &password, &apitoken, &verbose)
+	runMeqa(&meqaPath, &swaggerPath, &planPath, &resultPath, &testToRun, &username, &password, &apitoken, &verbose, &selftest,
+		&waitForReady, &healthURL, &readyTimeout, &retryRun, &retryThreshold, &strict)
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,105 @@
+package mqplan
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// APIKey is the value of an apiKey security scheme. Name is either the name of the security definition
+// or the header or query parameter the key is sent in. In is where the key is sent when the swagger
+// doesn't tell, header or query.
+type APIKey struct {
+	Name  string
+	In    string
+	Value string
+}
+
+// APIKeys implements flag.Value so the keys can be set through repeated "-k api_key=secret". The location
+// is given as a prefix when the swagger doesn't declare the key, e.g. "-k header:X-API-Key=secret".
+type APIKeys []APIKey
+
+func (keys *APIKeys) String() string {
+	var list []string
+	for _, key := range *keys {
+		name := key.Name
+		if len(key.In) > 0 {
+			name = key.In + ":" + name
+		}
+		list = append(list, name+"="+redacted)
+	}
+	return strings.Join(list, ",")
+}
+
+func (keys *APIKeys) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || len(kv[0]) == 0 {
+		return fmt.Errorf("invalid api key %s, the format is [header:|query:]name=value", s)
+	}
+	key := APIKey{Name: kv[0], Value: kv[1]}
+	for _, in := range []string{"header", "query"} {
+		if strings.HasPrefix(key.Name, in+":") {
+			key.In = in
+			key.Name = key.Name[len(in)+1:]
+		}
+	}
+	if len(key.Name) == 0 {
+		return fmt.Errorf("invalid api key %s, the format is [header:|query:]name=value", s)
+	}
+	*keys = append(*keys, key)
+	return nil
+}
+
+// CheckAPIKeys returns an error if we can't tell where to send one of the plan's api keys.
+func (plan *TestPlan) CheckAPIKeys() error {
+	for _, key := range plan.APIKeys {
+		if len(key.In) > 0 || plan.swagger == nil {
+			continue
+		}
+		found := false
+		for name, scheme := range plan.swagger.SecurityDefinitions {
+			if scheme != nil && scheme.Type == "apiKey" && (name == key.Name || scheme.Name == key.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+				"no apiKey security definition %s in the swagger, use header:%s=... or query:%s=... to tell where to send it",
+				key.Name, key.Name, key.Name))
+		}
+	}
+	return nil
+}
+
+// SetAPIKeys sets the plan's api keys on the request, in the header or query parameter the operation's
+// apiKey security schemes ask for. The keys with an explicit location that the swagger doesn't declare
+// are sent with every request.
+func (t *Test) SetAPIKeys(plan *TestPlan, req *resty.Request) {
+	if len(plan.APIKeys) == 0 || plan.swagger == nil {
+		return
+	}
+	schemes := plan.swagger.APIKeySchemes(t.op)
+	for _, key := range plan.APIKeys {
+		name, in := key.Name, key.In
+		for definition, scheme := range plan.swagger.SecurityDefinitions {
+			if scheme != nil && scheme.Type == "apiKey" && (definition == key.Name || scheme.Name == key.Name) {
+				// The key belongs to a declared scheme, only send it to the operations that use it.
+				name, in = scheme.Name, ""
+				if _, ok := schemes[definition]; ok {
+					in = scheme.In
+				}
+				break
+			}
+		}
+		switch in {
+		case "header":
+			req.SetHeader(name, key.Value)
+		case "query":
+			req.SetQueryParam(name, key.Value)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// An assertion compares a field in the response body with the number of objects of a class in
+// the object DB, e.g. $.totalCount >= objdb.count("User").
+var assertionRegex = regexp.MustCompile(`^\s*\$\.([^\s=!<>]+)\s*(==|!=|>=|<=|>|<)\s*objdb\.count\(\s*"([^"]+)"\s*\)\s*$`)
+
+// getField follows the dot separated path into the object. Array elements are addressed by their index.
+func getField(obj interface{}, path string) interface{} {
+	for _, field := range strings.Split(path, ".") {
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			obj = o[field]
+		case []interface{}:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= len(o) {
+				return nil
+			}
+			obj = o[i]
+		default:
+			return nil
+		}
+	}
+	return obj
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func compareNumbers(observed float64, op string, expected float64) bool {
+	switch op {
+	case "==":
+		return observed == expected
+	case "!=":
+		return observed != expected
+	case ">=":
+		return observed >= expected
+	case "<=":
+		return observed <= expected
+	case ">":
+		return observed > expected
+	case "<":
+		return observed < expected
+	}
+	return false
+}
+
+// CheckAssertions verifies the assertions in the test's expect section against the response object.
+func (t *Test) CheckAssertions(resultObj interface{}) error {
+	assertions, ok := t.Expect[ExpectAssertions].([]interface{})
+	if !ok {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("expect.%s should be a list", ExpectAssertions))
+	}
+	for _, a := range assertions {
+		assertion, _ := a.(string)
+		m := assertionRegex.FindStringSubmatch(assertion)
+		if m == nil {
+			return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid assertion: %v", a))
+		}
+		field, op, className := m[1], m[2], m[3]
+		if t.db.GetSchema(className) == nil {
+			return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("assertion %s: class %s not found", assertion, className))
+		}
+		expected := len(t.db.Find(className, nil, nil, mqswag.MatchAlways, -1))
+		value := getField(resultObj, field)
+		observed, ok := toFloat(value)
+		if !ok || !compareNumbers(observed, op, float64(expected)) {
+			fmt.Printf("... checking assertion %s, expected count: %d observed: %v. Fail\n", assertion, expected, value)
+			return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+				"=== assertion failed: %s\nexpected count: %d\nobserved: %v\n===", assertion, expected, value))
+		}
+		fmt.Printf("... checking assertion %s. Success\n", assertion)
+	}
+	return nil
+}
+
+// The length of the body snippet we report when the body doesn't match the regex.
+const bodySnippetLength = 200
+
+// CheckBodyRegex verifies the raw response body against the expect section's bodyMatches regular
+// expression. This is mostly for text endpoints, e.g. text/plain or text/csv. The regex can optionally
+// be written as /regex/.
+func (t *Test) CheckBodyRegex(body []byte) error {
+	pattern, ok := t.Expect[ExpectBodyRegex].(string)
+	if !ok {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("expect.%s should be a string", ExpectBodyRegex))
+	}
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid %s regex %s: %s", ExpectBodyRegex, pattern, err.Error()))
+	}
+	if !re.Match(body) {
+		snippet := string(body)
+		if len(snippet) > bodySnippetLength {
+			snippet = snippet[:bodySnippetLength] + "..."
+		}
+		fmt.Printf("... checking body against /%s/. Fail\n", pattern)
+		return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+			"=== test failed, body doesn't match /%s/, got body:\n%s\n===", pattern, snippet))
+	}
+	fmt.Printf("... checking body against /%s/. Success\n", pattern)
+	return nil
+}
@@ -0,0 +1,138 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// The values of the status field that mean the asynchronous operation is still running.
+var asyncPendingStates = []string{"pending", "queued", "accepted", "running", "processing", "in_progress", "inprogress"}
+
+func isPendingState(state interface{}) bool {
+	s, ok := state.(string)
+	if !ok {
+		return false
+	}
+	s = strings.ToLower(s)
+	for _, pending := range asyncPendingStates {
+		if s == pending {
+			return true
+		}
+	}
+	return false
+}
+
+// pollsAsync returns whether we should poll for the final result of the response. We don't when
+// the test expects the 202 itself.
+func (t *Test) pollsAsync(plan *TestPlan, resp *resty.Response) bool {
+	if plan.AsyncTimeout <= 0 || resp.StatusCode() != http.StatusAccepted {
+		return false
+	}
+	if t.Expect != nil {
+		if status, ok := t.Expect[ExpectStatus].(int); ok && status == http.StatusAccepted {
+			return false
+		}
+	}
+	return true
+}
+
+// asyncLocation finds the status url in the response, either in the header named by the plan's
+// AsyncLocation or, when that starts with "$.", in the body field. The url is resolved against base.
+func asyncLocation(plan *TestPlan, resp *resty.Response, base string) string {
+	var location string
+	if strings.HasPrefix(plan.AsyncLocation, "$.") {
+		body, err := DecodeJSON(resp.Body())
+		if err == nil {
+			if s, ok := getField(body, plan.AsyncLocation[2:]).(string); ok {
+				location = s
+			}
+		}
+	} else {
+		location = resp.Header().Get(plan.AsyncLocation)
+	}
+	if len(location) == 0 {
+		return ""
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return location
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	return baseURL.ResolveReference(ref).String()
+}
+
+// pollRequest creates the request to the status url, with the same authentication as the test.
+func (t *Test) pollRequest(tc *TestSuite, location string) (*resty.Request, error) {
+	req := tc.R()
+	t.SetHeaders(tc.plan, req)
+	if t.Anonymous {
+		return req, nil
+	}
+	if tc.plan.SigV4 {
+		poll := &Test{Method: "get", op: &spec.Operation{}}
+		return req, poll.SignSigV4(tc.plan, req, location, time.Now())
+	}
+	if len(tc.ApiToken) > 0 {
+		req.SetAuthToken(tc.ApiToken)
+	} else if len(tc.Username) > 0 {
+		req.SetBasicAuth(tc.Username, tc.Password)
+	}
+	return req, nil
+}
+
+// PollAsync polls the status url of an accepted (202) operation until it reaches a terminal state, and
+// returns the final response. The operation is still running while the status url returns 202, or while
+// its body has the plan's AsyncStatusField set to a pending state like "running".
+func (t *Test) PollAsync(tc *TestSuite, resp *resty.Response, path string) (*resty.Response, error) {
+	plan := tc.plan
+	location := asyncLocation(plan, resp, path)
+	if len(location) == 0 {
+		return nil, mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf(
+			"%s %s returned 202 without a status url in %s", t.Method, t.Path, plan.AsyncLocation))
+	}
+	fmt.Printf("... operation accepted, polling %s\n", location)
+
+	deadline := time.Now().Add(plan.AsyncTimeout)
+	for {
+		time.Sleep(plan.AsyncPollInterval)
+		req, err := t.pollRequest(tc, location)
+		if err != nil {
+			return nil, err
+		}
+		pollResp, err := req.Get(location)
+		if err != nil {
+			return nil, mqutil.NewError(mqutil.ErrHttp, err.Error())
+		}
+		mqutil.Logger.Printf("polled %s: %s", location, pollResp.Status())
+
+		pending := pollResp.StatusCode() == http.StatusAccepted
+		if pending {
+			if next := asyncLocation(plan, pollResp, location); len(next) > 0 {
+				location = next
+			}
+		} else if len(plan.AsyncStatusField) > 0 && pollResp.StatusCode() >= 200 && pollResp.StatusCode() < 300 {
+			if body, err := DecodeJSON(pollResp.Body()); err == nil {
+				pending = isPendingState(getField(body, plan.AsyncStatusField))
+			}
+		}
+		if !pending {
+			fmt.Printf("... operation completed with status %d\n", pollResp.StatusCode())
+			return pollResp, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+				"=== test failed, %s %s didn't complete in %v ===", t.Method, t.Path, plan.AsyncTimeout))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"regexp"
+	"sync"
+)
+
+// testBatch collects the results of the tests of a suite that run at the same time, by their position in
+// the batch, so that they are added to the results in the order of the suite however they finish.
+type testBatch struct {
+	mutex   sync.Mutex
+	results [][]*Test
+}
+
+func (b *testBatch) add(t *Test) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.results[t.slot] = append(b.results[t.slot], t)
+}
+
+// historyRefRegex finds the tests a parameter takes its value from, as in {{test1.output.id}}.
+var historyRefRegex = regexp.MustCompile(`{{\s*([^.}\s]+)\.`)
+
+// refersTo returns whether the parameters of the test take a value from the output of one of the tests.
+func (t *Test) refersTo(tests []*Test) bool {
+	data, err := json.Marshal([]interface{}{t.PathParams, t.QueryParams, t.HeaderParams, t.FormParams, t.BodyParams})
+	if err != nil {
+		return true
+	}
+	for _, match := range historyRefRegex.FindAllStringSubmatch(string(data), -1) {
+		for _, test := range tests {
+			if test.Name == match[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// concurrent returns whether the test can run at the same time as the tests of the batch. Only the tests
+// of safe operations do, as they don't change the server or the objects the later tests use, and only
+// when they don't take a parameter from the output of a test in the batch. The other tests run alone, in
+// the order of the suite.
+func (plan *TestPlan) concurrent(test *Test, batch []*Test) bool {
+	if plan.SuiteParallel <= 1 || plan.swagger == nil || len(test.Ref) != 0 || test.Name == MeqaInit || len(test.Data) != 0 {
+		return false
+	}
+	return test.IsReadOnly(plan.swagger) && !test.refersTo(batch)
+}
+
+// runBatch runs the tests of the suite on up to SuiteParallel goroutines. Returns the error of the first
+// test of the batch that failed, once they have all finished.
+func (plan *TestPlan) runBatch(tc *TestSuite, tests []*Test, parentTest *Test) error {
+	if len(tests) == 1 {
+		return plan.runTest(tc, tests[0], parentTest, nil, 0)
+	}
+	batch := &testBatch{results: make([][]*Test, len(tests))}
+	errs := make([]error, len(tests))
+	workers := make(chan struct{}, plan.SuiteParallel)
+	var wg sync.WaitGroup
+	for i, test := range tests {
+		member := *test
+		member.batch, member.slot = batch, i
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(i int, member *Test) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			errs[i] = plan.runTest(tc, member, parentTest, nil, 0)
+		}(i, &member)
+	}
+	wg.Wait()
+
+	for _, results := range batch.results {
+		for _, t := range results {
+			t.batch = nil
+			plan.addResult(tc, t)
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
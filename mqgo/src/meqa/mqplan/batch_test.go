@@ -0,0 +1,77 @@
+package mqplan
+
+import (
+	"testing"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+)
+
+func TestRefersTo(t *testing.T) {
+	batch := []*Test{{Name: "get_pet_1"}, {Name: "get_store_2"}}
+	cases := []struct {
+		name   string
+		params TestParams
+		refers bool
+	}{
+		{"no parameters", TestParams{}, false},
+		{"literal values", TestParams{PathParams: map[string]interface{}{"id": 1}, QueryParams: map[string]interface{}{"q": "x"}}, false},
+		{"path from the batch", TestParams{PathParams: map[string]interface{}{"id": "{{get_pet_1.outputs.id}}"}}, true},
+		{"spaces in the template", TestParams{QueryParams: map[string]interface{}{"id": "{{ get_store_2.outputs.id }}"}}, true},
+		{"header from the batch", TestParams{HeaderParams: map[string]interface{}{"X-Id": "{{get_pet_1.outputs.id}}"}}, true},
+		{"form from the batch", TestParams{FormParams: map[string]interface{}{"id": "{{get_pet_1.inputs.id}}"}}, true},
+		{"nested body field", TestParams{BodyParams: map[string]interface{}{
+			"owner": map[string]interface{}{"ids": []interface{}{"{{get_store_2.outputs.id}}"}}}}, true},
+		{"test outside of the batch", TestParams{PathParams: map[string]interface{}{"id": "{{post_pet_0.outputs.id}}"}}, false},
+		{"a prefix of a name in the batch", TestParams{PathParams: map[string]interface{}{"id": "{{get_pet.outputs.id}}"}}, false},
+		{"not a template", TestParams{PathParams: map[string]interface{}{"id": "get_pet_1.outputs.id"}}, false},
+	}
+	for _, c := range cases {
+		test := &Test{Name: "get_owner_3", TestParams: c.params}
+		if refers := test.refersTo(batch); refers != c.refers {
+			t.Errorf("%s: refersTo = %t, expecting %t", c.name, refers, c.refers)
+		}
+	}
+	test := &Test{Name: "get_owner_3", TestParams: TestParams{PathParams: map[string]interface{}{"id": "{{get_pet_1.outputs.id}}"}}}
+	if test.refersTo(nil) {
+		t.Errorf("expecting no reference to an empty batch")
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	sideEffects := &spec.Operation{VendorExtensible: spec.VendorExtensible{Extensions: spec.Extensions{"x-side-effects": true}}}
+	swagger := &mqswag.Swagger{SwaggerProps: spec.SwaggerProps{Paths: &spec.Paths{Paths: map[string]spec.PathItem{
+		"/pets":            {PathItemProps: spec.PathItemProps{Get: &spec.Operation{}, Post: &spec.Operation{}, Head: &spec.Operation{}}},
+		"/pets/{id}/visit": {PathItemProps: spec.PathItemProps{Get: sideEffects}},
+	}}}}
+	batch := []*Test{{Name: "get_pets_1", Path: "/pets", Method: "get"}}
+	cases := []struct {
+		name       string
+		parallel   int
+		test       *Test
+		concurrent bool
+	}{
+		{"a get", 4, &Test{Name: "get_pets_2", Path: "/pets", Method: "get"}, true},
+		{"a head", 4, &Test{Name: "head_pets_2", Path: "/pets", Method: "head"}, true},
+		{"without -suite-parallel", 1, &Test{Name: "get_pets_2", Path: "/pets", Method: "get"}, false},
+		{"a post", 4, &Test{Name: "post_pets_2", Path: "/pets", Method: "post"}, false},
+		{"a get with side effects", 4, &Test{Name: "get_visit_2", Path: "/pets/{id}/visit", Method: "get"}, false},
+		{"an unknown path", 4, &Test{Name: "get_stores_2", Path: "/stores", Method: "get"}, false},
+		{"a reference to a suite", 4, &Test{Name: "get_pets_2", Path: "/pets", Method: "get", Ref: "other"}, false},
+		{"a data-driven test", 4, &Test{Name: "get_pets_2", Path: "/pets", Method: "get", Data: "pets.csv"}, false},
+		{"the init test", 4, &Test{Name: MeqaInit}, false},
+		{"a get using the batch", 4, &Test{Name: "get_pets_2", Path: "/pets", Method: "get",
+			TestParams: TestParams{QueryParams: map[string]interface{}{"after": "{{get_pets_1.outputs.id}}"}}}, false},
+	}
+	for _, c := range cases {
+		plan := &TestPlan{SuiteParallel: c.parallel, swagger: swagger}
+		if concurrent := plan.concurrent(c.test, batch); concurrent != c.concurrent {
+			t.Errorf("%s: concurrent = %t, expecting %t", c.name, concurrent, c.concurrent)
+		}
+	}
+	plan := &TestPlan{SuiteParallel: 4}
+	if plan.concurrent(&Test{Name: "get_pets_2", Path: "/pets", Method: "get"}, batch) {
+		t.Errorf("expecting no batches without a swagger")
+	}
+}
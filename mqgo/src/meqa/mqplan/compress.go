@@ -0,0 +1,99 @@
+package mqplan
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompressor sends the requests with its transport, asking for gzip and deflate responses, and
+// decompresses the bodies of the responses that are. Go's transport only takes care of gzip, and only
+// when it set the Accept-Encoding header itself.
+type decompressor struct {
+	transport *http.Transport
+	compress  bool // send the Accept-Encoding header
+}
+
+func (d *decompressor) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d.compress && len(req.Header.Get("Accept-Encoding")) == 0 {
+		// A RoundTripper must not change the request.
+		r := req.WithContext(req.Context())
+		r.Header = make(http.Header)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		r.Header.Set("Accept-Encoding", "gzip, deflate")
+		req = r
+	}
+	resp, err := d.transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return resp, nil
+	}
+	resp.Body = &decompressedBody{body: resp.Body, encoding: encoding}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressedBody decompresses the body on the first read, so that the empty bodies of HEAD and 204
+// responses aren't an error.
+type decompressedBody struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	err      error
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		buffered := bufio.NewReader(b.body)
+		if _, err := buffered.Peek(1); err != nil {
+			b.err = err
+		} else if b.encoding == "deflate" {
+			b.reader, b.err = deflateReader(buffered)
+		} else {
+			b.reader, b.err = gzip.NewReader(buffered)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decompressedBody) Close() error {
+	return b.body.Close()
+}
+
+// deflateReader reads a deflate body. It should be zlib wrapped, but some servers send raw deflate.
+func deflateReader(r *bufio.Reader) (io.Reader, error) {
+	header, err := r.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(r)
+	}
+	return flate.NewReader(r), nil
+}
+
+// decompressing returns the transport of the sessions, which sends the requests with the given transport
+// through a decompressor. Resty only takes an *http.Transport, so the decompressor is registered as its
+// round tripper for http and https.
+func (plan *TestPlan) decompressing(transport *http.Transport) *http.Transport {
+	transport.DisableCompression = true
+	d := &decompressor{transport: transport, compress: !plan.NoCompression}
+	// Without HTTP/2 on the outer transport, it doesn't register its own https round tripper.
+	outer := &http.Transport{TLSNextProto: make(map[string]func(string, *tls.Conn) http.RoundTripper)}
+	outer.RegisterProtocol("http", d)
+	outer.RegisterProtocol("https", d)
+	return outer
+}
@@ -0,0 +1,83 @@
+package mqplan
+
+import (
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// consumes returns the media types the operation accepts, falling back to the swagger's.
+func consumes(swagger *mqswag.Swagger, op *spec.Operation) []string {
+	if op != nil && len(op.Consumes) > 0 {
+		return op.Consumes
+	}
+	return swagger.Consumes
+}
+
+// ContentTypes returns the media types the test's operation accepts.
+func (t *Test) ContentTypes(swagger *mqswag.Swagger) []string {
+	pathItem, ok := swagger.Paths.Paths[t.Path]
+	if !ok {
+		return nil
+	}
+	return consumes(swagger, GetOperationByMethod(&pathItem, t.Method))
+}
+
+// resolveContentType picks the media type of the request body: the test's own, the plan's when the
+// operation accepts it, or the operation's only one.
+func (t *Test) resolveContentType(plan *TestPlan) {
+	if len(t.ContentType) > 0 {
+		return
+	}
+	types := consumes(t.db.Swagger, t.op)
+	if len(plan.ContentType) > 0 {
+		for _, mediaType := range types {
+			if mediaType == plan.ContentType {
+				t.ContentType = mediaType
+				return
+			}
+		}
+	}
+	if len(types) == 1 {
+		t.ContentType = types[0]
+	}
+}
+
+// bodyExample returns the example of the body parameter for the media type. Swagger 2 keeps the
+// examples per media type in the x-examples extension of the body parameter, the schema's example
+// is used when there is none for the type.
+func bodyExample(param *spec.Parameter, mediaType string) interface{} {
+	if examples, ok := param.Extensions["x-examples"].(map[string]interface{}); ok && len(mediaType) > 0 {
+		if example, ok := examples[mediaType]; ok {
+			return example
+		}
+	}
+	if param.Schema != nil && param.Schema.Example != nil {
+		return param.Schema.Example
+	}
+	return nil
+}
+
+// applyBodyExample uses the example for the test's media type as the body, unless the test sets the body.
+func (t *Test) applyBodyExample() {
+	if t.BodyParams != nil {
+		return
+	}
+	for i := range t.op.Parameters {
+		if t.op.Parameters[i].In != "body" {
+			continue
+		}
+		example := bodyExample(&t.op.Parameters[i], t.ContentType)
+		if example == nil {
+			return
+		}
+		if _, isString := example.(string); !isString {
+			if obj, err := mqutil.YamlObjToJsonObj(example); err == nil {
+				example = obj
+			}
+		}
+		t.BodyParams = example
+		return
+	}
+}
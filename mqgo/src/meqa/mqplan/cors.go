@@ -0,0 +1,54 @@
+package mqplan
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// headerListContains checks whether the comma separated header value contains the entry.
+func headerListContains(list string, entry string) bool {
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.EqualFold(v, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCORS issues an OPTIONS preflight request to the url the test just called, and verifies the
+// Access-Control-Allow-* headers in the response against the plan's CORS settings.
+func (t *Test) CheckCORS(plan *TestPlan, url string) error {
+	method := strings.ToUpper(t.Method)
+	req := resty.R()
+	req.SetHeader("Origin", plan.CORSOrigin)
+	req.SetHeader("Access-Control-Request-Method", method)
+	resp, err := req.Options(url)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("CORS preflight failed: %s", err.Error()))
+	}
+
+	var mismatches []string
+	allowOrigin := resp.Header().Get("Access-Control-Allow-Origin")
+	if allowOrigin != "*" && allowOrigin != plan.CORSOrigin {
+		mismatches = append(mismatches, fmt.Sprintf("Access-Control-Allow-Origin is '%s', expecting '%s'", allowOrigin, plan.CORSOrigin))
+	}
+	allowMethods := resp.Header().Get("Access-Control-Allow-Methods")
+	expectedMethods := append([]string{method}, plan.CORSMethods...)
+	for _, m := range expectedMethods {
+		m = strings.TrimSpace(m)
+		if !headerListContains(allowMethods, m) {
+			mismatches = append(mismatches, fmt.Sprintf("Access-Control-Allow-Methods is '%s', missing '%s'", allowMethods, m))
+		}
+	}
+	if len(mismatches) > 0 {
+		fmt.Printf("... checking CORS preflight. Fail\n")
+		return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf("=== CORS preflight check failed ===\n%s\n", strings.Join(mismatches, "\n")))
+	}
+	fmt.Printf("... checking CORS preflight. Success\n")
+	return nil
+}
@@ -0,0 +1,162 @@
+package mqplan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v2"
+
+	"meqa/mqswag"
+)
+
+// The coverage status of an operation. Covered and failed operations were exercised by the run.
+const (
+	CoverageCovered = "covered"
+	CoverageFailed  = "failed"
+	CoverageSkipped = "skipped"
+)
+
+// OperationCoverage is how the run exercised one operation of the swagger.
+type OperationCoverage struct {
+	Operation string `yaml:"operation"`
+	Status    string `yaml:"status"`
+	Tests     int    `yaml:"tests"`
+	Failed    int    `yaml:"failed,omitempty"`
+	Reason    string `yaml:"reason,omitempty"`
+}
+
+// CoverageReport lists every operation of the swagger, with the share of them the run exercised first.
+type CoverageReport struct {
+	Coverage   string               `yaml:"coverage"`
+	Operations int                  `yaml:"operations"`
+	Covered    int                  `yaml:"covered"`
+	Failed     int                  `yaml:"failed"`
+	Skipped    int                  `yaml:"skipped"`
+	Details    []*OperationCoverage `yaml:"details"`
+}
+
+// unresolvedParams describes the required parameters of the operation that the generator has no object
+// to take the value from, which is usually why no test calls it.
+func unresolvedParams(pathItem *spec.PathItem, op *spec.Operation) []string {
+	var unresolved []string
+	params := append(append([]spec.Parameter{}, pathItem.Parameters...), op.Parameters...)
+	for _, param := range params {
+		if !param.Required || param.In == "body" || mqswag.GetMeqaTag(param.Description) != nil {
+			continue
+		}
+		unresolved = append(unresolved, fmt.Sprintf("unresolved required %s parameter %s of type %s", param.In, param.Name, param.Type))
+	}
+	return unresolved
+}
+
+// notRunReason returns why the operation that no test of the run called was never attempted.
+func (plan *TestPlan) notRunReason(path string, method string, pathItem *spec.PathItem, op *spec.Operation) string {
+	test := &Test{Path: path, Method: method}
+	if op.Deprecated && !plan.IncludeDeprecated {
+		return "deprecated, use -include-deprecated to run it"
+	}
+	if plan.ReadOnly && !test.IsReadOnly(plan.swagger) {
+		return "not a read-only operation, with -read-only"
+	}
+	inPlan := false
+	for _, tc := range plan.SuiteList {
+		for _, t := range tc.Tests {
+			if t.Path == path && strings.ToLower(t.Method) == method {
+				inPlan = true
+			}
+		}
+	}
+	if inPlan {
+		return "in the plan, but none of its tests was reached, e.g. an earlier test of the suite failed"
+	}
+	if unresolved := unresolvedParams(pathItem, op); len(unresolved) > 0 {
+		return "no test in the plan, " + strings.Join(unresolved, ", ")
+	}
+	return "no test in the plan"
+}
+
+// Coverage returns how the last run exercised each operation of the swagger.
+func (plan *TestPlan) Coverage() *CoverageReport {
+	byOperation := make(map[string][]*Test)
+	for _, test := range plan.resultList {
+		key := strings.ToLower(test.Method) + " " + test.Path
+		byOperation[key] = append(byOperation[key], test)
+	}
+
+	report := &CoverageReport{}
+	if plan.swagger == nil {
+		return report
+	}
+	var paths []string
+	for path := range plan.swagger.Paths.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		pathItem := plan.swagger.Paths.Paths[path]
+		for _, method := range mqswag.MethodAll {
+			op := GetOperationByMethod(&pathItem, method)
+			if op == nil {
+				continue
+			}
+			c := &OperationCoverage{Operation: strings.ToUpper(method) + " " + path}
+			var skipNotes []string
+			for _, test := range byOperation[method+" "+path] {
+				if test.skipped() {
+					skipNotes = append(skipNotes, test.Notes...)
+					continue
+				}
+				c.Tests++
+				if test.err != nil {
+					c.Failed++
+				}
+			}
+			switch {
+			case c.Failed > 0:
+				c.Status = CoverageFailed
+				report.Failed++
+			case c.Tests > 0:
+				c.Status = CoverageCovered
+				report.Covered++
+			case len(skipNotes) > 0:
+				c.Status = CoverageSkipped
+				c.Reason = "skipped, " + strings.Join(uniqueStrings(skipNotes), ", ")
+				report.Skipped++
+			default:
+				c.Status = CoverageSkipped
+				c.Reason = plan.notRunReason(path, method, &pathItem, op)
+				report.Skipped++
+			}
+			report.Details = append(report.Details, c)
+		}
+	}
+	report.Operations = len(report.Details)
+	if report.Operations > 0 {
+		report.Coverage = fmt.Sprintf("%.1f%%", float64(report.Covered+report.Failed)*100/float64(report.Operations))
+	}
+	return report
+}
+
+func uniqueStrings(list []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// WriteCoverageToFile writes the coverage report of the last run to the file as yaml.
+func (plan *TestPlan) WriteCoverageToFile(path string) error {
+	data, err := yaml.Marshal(plan.Coverage())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
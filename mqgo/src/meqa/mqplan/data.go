@@ -0,0 +1,125 @@
+package mqplan
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v2"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// The column name prefixes that put the column's value into a specific parameter location.
+var dataLocations = []string{"pathParams", "queryParams", "formParams", "headerParams", "bodyParams"}
+
+// LoadData reads the CSV file of a data-driven test. The first row is the header with the column names,
+// every other row becomes a map from the column name to the cell.
+func (plan *TestPlan) LoadData(path string) ([]map[string]string, error) {
+	if !filepath.IsAbs(path) && len(plan.dataDir) > 0 {
+		path = filepath.Join(plan.dataDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't open data file %s: %s", path, err.Error()))
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse data file %s: %s", path, err.Error()))
+	}
+	if len(records) == 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("data file %s has no header", path))
+	}
+	header := records[0]
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for i, column := range header {
+			row[strings.TrimSpace(column)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isDataLocation(s string) bool {
+	for _, location := range dataLocations {
+		if s == location {
+			return true
+		}
+	}
+	return false
+}
+
+// dataValue parses the cell the way the same value in the test plan would be parsed, so that
+// numbers and booleans keep their types. An empty cell is an empty string.
+func dataValue(cell string) interface{} {
+	var value interface{}
+	if len(cell) == 0 || yaml.Unmarshal([]byte(cell), &value) != nil || value == nil {
+		return cell
+	}
+	if v, err := mqutil.YamlObjToJsonObj(value); err == nil {
+		return v
+	}
+	return cell
+}
+
+func setParam(m *map[string]interface{}, name string, value interface{}) {
+	if *m == nil {
+		*m = make(map[string]interface{})
+	}
+	(*m)[name] = value
+}
+
+// ApplyDataRow sets the test's parameters from a row of its data file. A column named like
+// "queryParams.limit" goes to that location. Otherwise the column is matched against the operation's
+// parameters by name, and what doesn't match any parameter becomes a field of the body.
+func (t *Test) ApplyDataRow(swagger *mqswag.Swagger, row map[string]string) error {
+	var params []spec.Parameter
+	if pathItem, ok := swagger.Paths.Paths[t.Path]; ok {
+		if op := GetOperationByMethod(&pathItem, t.Method); op != nil {
+			params = ParamsAdd(op.Parameters, pathItem.Parameters)
+		}
+	}
+
+	for column, cell := range row {
+		value := dataValue(cell)
+		location, name := "", column
+		if ar := strings.SplitN(column, ".", 2); len(ar) == 2 && isDataLocation(ar[0]) {
+			location, name = ar[0], ar[1]
+		} else {
+			for _, p := range params {
+				if p.Name == column && p.In != "body" {
+					location = p.In + "Params"
+					break
+				}
+			}
+		}
+		switch location {
+		case "pathParams":
+			setParam(&t.PathParams, name, value)
+		case "queryParams":
+			setParam(&t.QueryParams, name, value)
+		case "formDataParams", "formParams":
+			setParam(&t.FormParams, name, value)
+		case "headerParams":
+			setParam(&t.HeaderParams, name, value)
+		default:
+			if t.BodyParams == nil {
+				t.BodyParams = make(map[string]interface{})
+			}
+			body, ok := t.BodyParams.(map[string]interface{})
+			if !ok {
+				return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("column %s of %s: the body is not an object", column, t.Data))
+			}
+			body[name] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package mqplan
+
+import (
+	"fmt"
+	"time"
+
+	"meqa/mqutil"
+)
+
+// The note on tests skipped because the run went past its deadline before they started.
+const NoteDeadline = "deadline"
+
+// The note on tests skipped because an earlier test failed, with FailFast set.
+const NoteFailFast = "failfast"
+
+// The note on tests skipped because the run was interrupted, e.g. with Ctrl-C, before they started.
+const NoteInterrupted = "interrupted"
+
+// The note on tests that got no response within the plan's timeout.
+const NoteTimeout = "timeout"
+
+// PastDeadline returns whether the run went past the plan's deadline, if it has one.
+func (plan *TestPlan) PastDeadline() bool {
+	return !plan.Deadline.IsZero() && time.Now().After(plan.Deadline)
+}
+
+// stopped returns why the run stopped, as the note of the tests it skips, or "" if it goes on.
+func (plan *TestPlan) stopped() string {
+	if plan.PastDeadline() {
+		return NoteDeadline
+	}
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	return plan.stopNote
+}
+
+// Stopped returns whether the run stopped before its end, past its deadline, at the first failure or
+// because it was interrupted.
+func (plan *TestPlan) Stopped() bool {
+	return len(plan.stopped()) > 0
+}
+
+// Interrupt stops the run. The tests being run finish, and the ones not started yet are skipped. It's safe
+// to call from another goroutine, e.g. a signal handler.
+func (plan *TestPlan) Interrupt() {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if len(plan.stopNote) == 0 {
+		plan.stopNote = NoteInterrupted
+	}
+}
+
+// Interrupted returns whether the run was stopped by Interrupt.
+func (plan *TestPlan) Interrupted() bool {
+	return plan.stopped() == NoteInterrupted
+}
+
+// failFast stops the run at the failure of the test, with FailFast set.
+func (plan *TestPlan) failFast(t *Test) {
+	if !plan.FailFast {
+		return
+	}
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if len(plan.stopNote) == 0 {
+		plan.stopNote = NoteFailFast
+		plan.FirstFailure = t.Name
+	}
+}
+
+// skipStopped records the tests as skipped with the note of why the run stopped before they started.
+func (plan *TestPlan) skipStopped(tc *TestSuite, tests []*Test, note string) {
+	for _, test := range tests {
+		if len(test.Ref) != 0 || test.Name == MeqaInit {
+			continue
+		}
+		switch note {
+		case NoteDeadline:
+			mqutil.Logger.Printf("skipping %s, the run is past its deadline", test.Name)
+			fmt.Printf("\nSkipping test case: %s (past the deadline)\n", test.Name)
+		case NoteInterrupted:
+			mqutil.Logger.Printf("skipping %s, the run was interrupted", test.Name)
+			fmt.Printf("\nSkipping test case: %s (interrupted)\n", test.Name)
+		default:
+			mqutil.Logger.Printf("skipping %s, the run stopped at the first failure", test.Name)
+			fmt.Printf("\nSkipping test case: %s (stopped at the first failure)\n", test.Name)
+		}
+		skipped := test.Duplicate()
+		skipped.Notes = []string{note}
+		plan.addResult(tc, skipped)
+		plan.mutex.Lock()
+		switch note {
+		case NoteDeadline:
+			plan.SkippedDeadline++
+		case NoteInterrupted:
+			plan.SkippedInterrupted++
+		default:
+			plan.SkippedFailFast++
+		}
+		plan.mutex.Unlock()
+	}
+}
+
+// timedOut returns whether the call failed because no response came within the plan's timeout.
+func timedOut(err error) bool {
+	timeout, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && timeout.Timeout()
+}
+
+// isSkipNote returns whether the note is on tests that were not run, because their route is unreachable,
+// or the run stopped before them, or their suite was filtered out or passed before -rerun.
+func isSkipNote(note string) bool {
+	return note == NoteUnreachable || note == NoteDeadline || note == NoteFailFast || note == NoteInterrupted ||
+		note == NoteFiltered || note == NotePassedPreviously || note == NoteExisting
+}
+
+// hasNote returns whether the test has the note.
+func (t *Test) hasNote(note string) bool {
+	for _, n := range t.Notes {
+		if n == note {
+			return true
+		}
+	}
+	return false
+}
+
+// skipped returns whether the test was not run, see isSkipNote.
+func (t *Test) skipped() bool {
+	for _, note := range t.Notes {
+		if isSkipNote(note) {
+			return true
+		}
+	}
+	return false
+}
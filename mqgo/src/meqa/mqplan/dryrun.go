@@ -0,0 +1,42 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The note on the tests of a dry run, their responses came from the stub server.
+const NoteDryRun = "dry-run"
+
+// The token sent instead of the OAuth2 one in a dry run, which doesn't call the token url.
+const dryRunToken = "dry-run-token"
+
+// NewDryRunServer starts the stub server the requests of a dry run go to instead of the real one. It
+// always answers with a response generated from the swagger, so that the objects it returns, with
+// their made up ids, fill the parameters of the later tests like real ones would. The caller should
+// Close the server when done.
+func NewDryRunServer(plan *TestPlan) *SelfTestServer {
+	s := NewSelfTestServer(plan)
+	s.mockOnly = true
+	plan.dryRun = s
+	return s
+}
+
+// printDryRun prints the request the test would send, with the credentials redacted.
+func (t *Test) printDryRun(record *requestRecord) {
+	fmt.Printf("... dry run, would send %s %s\n", record.Method, record.URL)
+	var names []string
+	for name := range record.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("...     %s: %s\n", name, strings.Join(record.Headers[name], ", "))
+	}
+	if record.Body != nil {
+		body, _ := json.MarshalIndent(record.Body, "...     ", "    ")
+		fmt.Printf("...     %s\n", string(body))
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,15 +23,34 @@ import (
 
 	"github.com/go-openapi/spec"
 	"github.com/lucasjones/reggen"
-	"github.com/satori/go.uuid"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 const (
-	ExpectStatus = "status"
-	ExpectBody   = "body"
+	ExpectStatus     = "status"
+	ExpectBody       = "body"
+	ExpectAssertions = "assertions"
+	ExpectBodyRegex  = "bodyMatches"
 )
 
+// The notes on tests whose response couldn't be validated because the operation has no response schema,
+// and on the tests whose error response was validated, followed by the schema.
+const (
+	NoteNoSchema    = "no-schema"
+	NoteErrorSchema = "error-schema"
+)
+
+// schemaName returns the definition the schema refers to, or "inline".
+func schemaName(schema *mqswag.Schema) string {
+	if schema.Ref.GetURL() != nil {
+		tokens := schema.Ref.GetPointer().DecodedTokens()
+		if len(tokens) > 0 {
+			return tokens[len(tokens)-1]
+		}
+	}
+	return "inline"
+}
+
 func GetBaseURL(swagger *mqswag.Swagger) string {
 	// Prefer http, then https, then others.
 	scheme := ""
@@ -51,6 +72,16 @@ func GetBaseURL(swagger *mqswag.Swagger) string {
 	return scheme + "://" + swagger.Host + swagger.BasePath
 }
 
+// ParseBaseURL checks the url that overrides the swagger's scheme, host and basePath, and returns it ready
+// to be joined with the paths of the operations.
+func ParseBaseURL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+		return "", mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid base url %s, expecting something like https://staging.example.com/v2", base))
+	}
+	return strings.TrimRight(base, "/"), nil
+}
+
 // Post: old - nil, new - the new object we create.
 // Put, patch: old - the old object, new - the new one.
 // Get: old - the old object, new - the one we get from the server.
@@ -97,24 +128,74 @@ func (comp *Comparison) SetForOp(op string, key string, value interface{}) *Comp
 // Test represents a test object in the DSL. Extra care needs to be taken to copy the
 // Test before running it, because running it would change the parameter maps.
 type Test struct {
-	Name       string                 `yaml:"name,omitempty"`
-	Path       string                 `yaml:"path,omitempty"`
-	Method     string                 `yaml:"method,omitempty"`
-	Ref        string                 `yaml:"ref,omitempty"`
-	Expect     map[string]interface{} `yaml:"expect,omitempty"`
-	Strict     bool                   `yaml:"strict,omitempty"`
-	TestParams `yaml:",inline,omitempty" json:",inline,omitempty"`
+	Name        string                 `yaml:"name,omitempty"`
+	Path        string                 `yaml:"path,omitempty"`
+	Method      string                 `yaml:"method,omitempty"`
+	Ref         string                 `yaml:"ref,omitempty"`
+	Expect      map[string]interface{} `yaml:"expect,omitempty"`
+	Strict      bool                   `yaml:"strict,omitempty"`
+	Serial      bool                   `yaml:"serial,omitempty"`      // never call the operation concurrently with other calls to it
+	Data        string                 `yaml:"data,omitempty"`        // a CSV file, the test runs once per row
+	Row         int                    `yaml:"row,omitempty"`         // the data row of this run, starting from 1
+	Anonymous   bool                   `yaml:"anonymous,omitempty"`   // call the operation without credentials
+	ContentType string                 `yaml:"contentType,omitempty"` // the media type the body is sent as
+	TestParams  `yaml:",inline,omitempty" json:",inline,omitempty"`
+
+	// The sizes of the request and response bodies in bytes, recorded in the result file.
+	RequestBytes  int `yaml:"requestBytes,omitempty"`
+	ResponseBytes int `yaml:"responseBytes,omitempty"`
+
+	// When the call started and ended, as RFC3339 timestamps in UTC.
+	StartedAt string `yaml:"startedAt,omitempty"`
+	EndedAt   string `yaml:"endedAt,omitempty"`
+
+	// Notes about how the test was checked, e.g. no-schema when there was no response schema to validate against.
+	Notes []string `yaml:"notes,omitempty"`
+
+	// The top level suite the test ran in, and why it failed, so that -rerun can find the failed suites.
+	Suite string `yaml:"suite,omitempty"`
+	Error string `yaml:"error,omitempty"`
+
+	// Where the response doesn't match its schema, as JSON paths of the offending fields, with -validate-response.
+	Violations []string `yaml:"violations,omitempty"`
+
+	// How many times the call was retried after a transient failure.
+	Retries int `yaml:"retries,omitempty"`
+
+	// How many pages of the collection were read, with -max-pages.
+	Pages int `yaml:"pages,omitempty"`
+
+	// How long the call took, in milliseconds.
+	LatencyMs float64 `yaml:"latencyMs,omitempty"`
+
+	// The files uploaded for the file parameters, by parameter: the fixture's path, or generated.
+	Fixtures map[string]string `yaml:"fixtures,omitempty"`
 
 	startTime time.Time
 	stopTime  time.Time
+	depth     int // current nesting level while generating arrays and objects
 
 	// Map of Object name (matching definitions) to the Comparison object.
 	// This tracks what objects we need to add to DB at the end of test.
 	comparisons map[string]([]*Comparison)
 
+	// The path parameters stored with the DB objects we picked for path parameters. For resources
+	// with composite keys, these let us fill the rest of the path with the values that belong together.
+	storedPathParams map[string]interface{}
+
+	request *requestRecord // The resolved request, only recorded when needed
+
+	negative *negativeCase // the constraint the test breaks, for the negative cases of -negative
+
+	files map[string]*fileUpload // the generated content of the file parameters without a fixture
+
+	batch *testBatch // the batch the test runs in with -suite-parallel, and its position in it
+	slot  int
+
 	tag   *mqswag.MeqaTag // The tag at the top level that describes the test
 	db    *mqswag.DB
 	suite *TestSuite
+	root  *TestSuite // the top level suite the test ran in
 	op    *spec.Operation
 	resp  *resty.Response
 	err   error
@@ -161,7 +242,21 @@ func (t *Test) Duplicate() *Test {
 	test.op = nil
 	test.resp = nil
 	test.comparisons = make(map[string]([]*Comparison))
+	test.storedPathParams = nil
+	test.request = nil
+	test.RequestBytes = 0
+	test.LatencyMs = 0
+	test.Pages = 0
+	test.Fixtures = nil
+	test.files = nil
+	test.ResponseBytes = 0
+	test.Notes = nil
+	test.Suite = ""
+	test.Error = ""
+	test.StartedAt = ""
+	test.EndedAt = ""
 	test.err = nil
+	test.depth = 0
 
 	return &test
 }
@@ -226,7 +321,7 @@ func (t *Test) AddObjectComparison(tag *mqswag.MeqaTag, obj map[string]interface
 	if len(class) == 0 {
 		cl, s := t.db.FindMatchingSchema(obj)
 		if s == nil {
-			mqutil.Logger.Printf("Can't find a known schema for obj %v", obj)
+			mqutil.Warnf("Can't find a known schema for obj %v", obj)
 			return
 		}
 		class = cl
@@ -323,8 +418,8 @@ func (t *Test) ProcessOneComparison(className string, method string, comp *Compa
 		t.db.Delete(className, comp.oldUsed, associations, mqutil.InterfaceEquals, -1)
 	} else if method == mqswag.MethodPost && comp.new != nil {
 		fmt.Printf("... adding entry to client DB. Success\n")
-		t.suite.db.Insert(className, comp.new, associations)
-		return t.db.Insert(className, comp.new, associations)
+		t.suite.db.Insert(className, comp.new, associations, t.PathParams)
+		return t.db.Insert(className, comp.new, associations, t.PathParams)
 	} else if (method == mqswag.MethodPatch || method == mqswag.MethodPut) && comp.new != nil {
 		fmt.Printf("... updating entry in client DB. Success\n")
 		t.suite.db.Update(className, comp.oldUsed, associations, mqutil.InterfaceEquals, comp.new, 1, method == mqswag.MethodPatch)
@@ -402,10 +497,12 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 	t.resp = resp
 	status := resp.StatusCode()
 	var respSpec *spec.Response
+	respSource := "default" // which of the operation's responses we check against
 	if t.op.Responses != nil {
 		respObject, ok := t.op.Responses.StatusCodeResponses[status]
 		if ok {
 			respSpec = &respObject
+			respSource = fmt.Sprint(status)
 			// useDefaultSpec = false
 		} else {
 			respSpec = t.op.Responses.Default
@@ -420,23 +517,42 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 	respSchema := (*mqswag.Schema)(respSpec.Schema)
 	var resultObj interface{}
 	if len(respBody) > 0 {
-		d := json.NewDecoder(bytes.NewReader(respBody))
-		d.UseNumber()
-		d.Decode(&resultObj)
+		contentType := resp.Header().Get("Content-Type")
+		handler := GetMimeHandler(contentType)
+		if handler == nil {
+			// Servers frequently don't set the content type right, so we always give json a try.
+			handler = DecodeJSON
+		}
+		var err error
+		resultObj, err = handler(respBody)
+		if err != nil {
+			mqutil.Warnf("can't decode response body of content type %s: %s", contentType, err.Error())
+		} else if isXML(contentType) && respSchema != nil {
+			// The xml values are text, they take the types of the schema like the json ones have.
+			resultObj = fromXML(resultObj, respSchema, t.db.Swagger)
+		}
 	}
 
 	// Before returning from this function, we should set the test's expect value to that
 	// of actual result. This allows us to print out a result report that is the same format
 	// as the test plan file, but with the expect value that reflects the current ground truth.
 	setExpect := func() {
+		assertions := t.Expect[ExpectAssertions]
+		bodyRegex := t.Expect[ExpectBodyRegex]
 		t.Expect = make(map[string]interface{})
 		t.Expect[ExpectStatus] = status
 		if resultObj != nil {
 			t.Expect[ExpectBody] = resultObj
 		}
+		if assertions != nil {
+			t.Expect[ExpectAssertions] = assertions
+		}
+		if bodyRegex != nil {
+			t.Expect[ExpectBodyRegex] = bodyRegex
+		}
 	}
 
-	if mqutil.Verbose {
+	if mqutil.IsDebug() {
 		fmt.Println("Verifying REST response")
 	}
 	// success based on return status
@@ -445,6 +561,12 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 	if tag != nil && tag.Flags&mqswag.FlagFail != 0 {
 		success = false
 	}
+	// With -redirects no-follow, a redirect that the operation declares is a response like a 2xx.
+	redirect := status >= 300 && status < 400 && respSource != "default" && t.suite != nil &&
+		t.suite.plan.Redirects == RedirectsNoFollow
+	if redirect && (tag == nil || tag.Flags&mqswag.FlagFail == 0) {
+		success = true
+	}
 
 	testSuccess := success
 	var expectedStatus interface{} = "success"
@@ -478,23 +600,74 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 		setExpect()
 		return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf("=== test failed, response code %d ===", status))
 	}
+	if t.Expect != nil && t.Expect[ExpectBodyRegex] != nil {
+		err := t.CheckBodyRegex(respBody)
+		if err != nil {
+			setExpect()
+			return err
+		}
+	}
+	if t.Expect != nil && t.Expect[ExpectAssertions] != nil {
+		err := t.CheckAssertions(resultObj)
+		if err != nil {
+			setExpect()
+			return err
+		}
+	}
 
 	// Check if the response obj and respSchema match
+	validate := t.suite != nil && t.suite.plan.ValidateResponse
+	if validate && respSchema == nil {
+		if t.suite.plan.StrictValidation {
+			fmt.Printf("... verifying response against openapi schema. Fail\n... no response schema for status %d\n", status)
+			setExpect()
+			return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf(
+				"=== test failed, %s %s has no response schema for status %d ===", t.Method, t.Path, status))
+		}
+		fmt.Printf("... verifying response against openapi schema. Skipped, no schema\n")
+		t.Notes = append(t.Notes, NoteNoSchema)
+	}
+	// With validate, the error responses are checked against the error schema the operation declares.
+	isError := (status < 200 || status >= 300) && !redirect
 	collection := make(map[string][]interface{})
 	objMatchesSchema := false
 	if resultObj != nil && respSchema != nil {
-		fmt.Printf("... verifying response against openapi schema. ")
+		if isError && validate {
+			t.Notes = append(t.Notes, fmt.Sprintf("%s %s (%s)", NoteErrorSchema, schemaName(respSchema), respSource))
+		}
+		if isError {
+			fmt.Printf("... verifying error response against the %s response schema. ", respSource)
+		} else {
+			fmt.Printf("... verifying response against openapi schema. ")
+		}
+		var violations []string
+		if validate && !isError {
+			violations = respSchema.Violations("$", resultObj, t.db.Swagger)
+		}
 		err := respSchema.Parses("", resultObj, collection, true, t.db.Swagger)
+		if len(violations) > 0 {
+			fmt.Printf("Fail\n... %s\n", strings.Join(violations, "\n... "))
+			t.Violations = violations
+			setExpect()
+			return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf(
+				"=== test failed, response doesn't match the openapi schema of the %s response ===\n%s",
+				respSource, strings.Join(violations, "\n")))
+		}
 		if err != nil {
 			fmt.Print("Fail\n")
 			objMatchesSchema = true
 			specBytes, _ := json.MarshalIndent(respSpec, "", "    ")
 			mqutil.Logger.Printf("server response doesn't match swagger spec: \n%s", string(specBytes))
-			if mqutil.Verbose {
+			if mqutil.IsDebug() {
 				// fmt.Printf("... openapi response schema: %s\n", string(specBytes))
 				// fmt.Printf("... response body: %s\n", string(respBody))
 				fmt.Println(err.Error())
 			}
+			if validate {
+				setExpect()
+				return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf(
+					"=== test failed, response doesn't match the openapi schema of the %s response ===\n%s", respSource, err.Error()))
+			}
 
 			// We ignore this if the response is success, and the spec we used is the default. This is a strong
 			// indicator that the author didn't spec out all the success cases.
@@ -507,6 +680,14 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 		} else {
 			fmt.Print("Success\n")
 		}
+		if validate {
+			err = respSchema.ValidateEnums("$", resultObj, t.db.Swagger)
+			if err != nil {
+				fmt.Printf("... checking enum values in response. Fail\n... %s\n", err.Error())
+				setExpect()
+				return err
+			}
+		}
 	}
 	if resultObj != nil && len(collection) == 0 && t.tag != nil && len(t.tag.Class) > 0 {
 		// try to resolve collection from the hint on the operation's description field.
@@ -618,7 +799,7 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 		// Add everything from the collection to the in-mem DB
 		for className, classList := range collection {
 			for _, entry := range classList {
-				t.db.Insert(className, entry, associations)
+				t.db.Insert(className, entry, associations, t.PathParams)
 			}
 		}
 	}
@@ -627,40 +808,105 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 	return nil
 }
 
+// sortQueryPairs sorts the key=value pairs of a query string by key, and then by value.
+func sortQueryPairs(pairs []string) {
+	sort.Slice(pairs, func(i, j int) bool {
+		ki, vi := splitQueryPair(pairs[i])
+		kj, vj := splitQueryPair(pairs[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return vi < vj
+	})
+}
+
+func splitQueryPair(pair string) (string, string) {
+	if i := strings.Index(pair, "="); i >= 0 {
+		return pair[:i], pair[i+1:]
+	}
+	return pair, ""
+}
+
 // SetRequestParameters sets the parameters. Returns the new request path.
 func (t *Test) SetRequestParameters(req *resty.Request) string {
+	// The file parameters are sent as the parts of a multipart request, the rest of formParams as its values.
 	files := make(map[string]string)
+	uploads := make(map[string]interface{})
 	for _, p := range t.op.Parameters {
-		if p.Type == "file" && t.FormParams[p.Name] != nil {
-			// for swagger 2 file type can only be in formData
-			if fname, ok := t.FormParams[p.Name].(string); ok {
-				files[p.Name] = fname
-				delete(t.FormParams, p.Name)
-			}
+		if p.Type != "file" || t.FormParams[p.Name] == nil {
+			continue
 		}
+		// for swagger 2 file type can only be in formData
+		if upload, ok := t.files[p.Name]; ok {
+			req.SetFileReader(p.Name, upload.fileName, bytes.NewReader(upload.content))
+			t.setFixture(p.Name, FixtureGenerated)
+		} else if fname, ok := t.FormParams[p.Name].(string); ok {
+			files[p.Name] = fname
+			t.setFixture(p.Name, fname)
+		} else {
+			continue
+		}
+		uploads[p.Name] = t.FormParams[p.Name]
+		delete(t.FormParams, p.Name)
 	}
 	if len(files) > 0 {
 		req.SetFiles(files)
 	}
 	if len(t.FormParams) > 0 {
 		req.SetFormData(mqutil.MapInterfaceToMapString(t.FormParams))
-		mqutil.InterfacePrint(map[string]interface{}{"formParams": t.FormParams}, mqutil.Verbose)
+		mqutil.InterfacePrint(map[string]interface{}{"formParams": t.FormParams}, mqutil.IsDebug())
 	}
-	for k, v := range files {
+	for k, v := range uploads {
 		t.FormParams[k] = v
 	}
 
+	// The empty query parameters that allow empty values are sent with the key only. These go into
+	// the raw query we append to the path, with the rest of the query when it must be sorted.
+	var rawQuery []string
+	queryParams := mqutil.MapCopy(t.QueryParams)
+	for _, p := range t.op.Parameters {
+		if p.In == "query" && p.AllowEmptyValue && t.QueryParams[p.Name] == "" {
+			rawQuery = append(rawQuery, url.QueryEscape(p.Name))
+			delete(queryParams, p.Name)
+		}
+	}
+	sortQuery := t.suite != nil && t.suite.plan.SortQuery
+	if sortQuery {
+		// We build the query string ourselves, so that the key-only parameters are sorted in as well.
+		for k, v := range mqutil.MapInterfaceToMapString(queryParams) {
+			rawQuery = append(rawQuery, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+		// So are the query parameters already set on the request, e.g. the api keys.
+		for k, values := range req.QueryParam {
+			for _, v := range values {
+				rawQuery = append(rawQuery, url.QueryEscape(k)+"="+url.QueryEscape(v))
+			}
+		}
+		req.QueryParam = url.Values{}
+	} else if len(queryParams) > 0 {
+		req.SetQueryParams(mqutil.MapInterfaceToMapString(queryParams))
+	}
 	if len(t.QueryParams) > 0 {
-		req.SetQueryParams(mqutil.MapInterfaceToMapString(t.QueryParams))
-		mqutil.InterfacePrint(map[string]interface{}{"queryParams": t.QueryParams}, mqutil.Verbose)
+		mqutil.InterfacePrint(map[string]interface{}{"queryParams": t.QueryParams}, mqutil.IsDebug())
+	}
+	if len(t.ContentType) > 0 && (t.BodyParams != nil || len(t.FormParams) > 0) {
+		req.SetHeader("Content-Type", t.ContentType)
+	}
+	if accept := t.accept(); len(accept) > 0 && len(req.Header.Get("Accept")) == 0 {
+		req.SetHeader("Accept", accept)
 	}
 	if t.BodyParams != nil {
-		req.SetBody(t.BodyParams)
-		mqutil.InterfacePrint(map[string]interface{}{"bodyParams": t.BodyParams}, mqutil.Verbose)
+		// The xml bodies are marshaled by the body schema, a string body is sent as is.
+		if _, isString := t.BodyParams.(string); isXML(t.ContentType) && !isString {
+			req.SetBody(t.xmlBody())
+		} else {
+			req.SetBody(t.BodyParams)
+		}
+		mqutil.InterfacePrint(map[string]interface{}{"bodyParams": t.BodyParams}, mqutil.IsDebug())
 	}
 	if len(t.HeaderParams) > 0 {
 		req.SetHeaders(mqutil.MapInterfaceToMapString(t.HeaderParams))
-		mqutil.InterfacePrint(map[string]interface{}{"headerParams": t.HeaderParams}, mqutil.Verbose)
+		mqutil.InterfacePrint(map[string]interface{}{"headerParams": t.HeaderParams}, mqutil.IsDebug())
 	}
 	path := t.Path
 	if len(t.PathParams) > 0 {
@@ -668,7 +914,15 @@ func (t *Test) SetRequestParameters(req *resty.Request) string {
 		for k, v := range PathParamsStr {
 			path = strings.Replace(path, "{"+k+"}", v, -1)
 		}
-		mqutil.InterfacePrint(map[string]interface{}{"pathParams": t.PathParams}, mqutil.Verbose)
+		mqutil.InterfacePrint(map[string]interface{}{"pathParams": t.PathParams}, mqutil.IsDebug())
+	}
+	if len(rawQuery) > 0 {
+		if sortQuery {
+			sortQueryPairs(rawQuery)
+		} else {
+			sort.Strings(rawQuery)
+		}
+		path += "?" + strings.Join(rawQuery, "&")
 	}
 	return path
 }
@@ -702,56 +956,163 @@ func (t *Test) CopyParent(parentTest *Test) {
 	}
 }
 
+// setCredentials sets the credentials of the suite on the request, unless the test is anonymous. Returns
+// whether they are an OAuth2 token, which can be renewed if the server rejects it.
+func (t *Test) setCredentials(tc *TestSuite, req *resty.Request) (bool, error) {
+	// With SigV4 the Authorization header is set when signing.
+	oauth2 := !t.Anonymous && !tc.plan.SigV4 && tc.plan.UsesOAuth2()
+	if t.Anonymous {
+		fmt.Printf("... calling without credentials\n")
+	} else if oauth2 {
+		token, err := tc.plan.OAuth2Token(false)
+		if err != nil {
+			return false, err
+		}
+		req.SetAuthToken(token)
+	} else if len(tc.ApiToken) > 0 && !tc.plan.SigV4 {
+		req.SetAuthToken(tc.ApiToken)
+	} else if len(tc.Username) > 0 && !tc.plan.SigV4 {
+		req.SetBasicAuth(tc.Username, tc.Password)
+	}
+	if !t.Anonymous {
+		t.SetAPIKeys(tc.plan, req)
+	}
+	return oauth2, nil
+}
+
 // Run runs the test. Returns the test result.
 func (t *Test) Run(tc *TestSuite) error {
+	mqutil.SetLogContext(tc.Name, t.Name)
 
-	mqutil.Logger.Print("\n--- " + t.Name)
-	fmt.Printf("\nRunning test case: %s\n", t.Name)
+	if t.Row > 0 {
+		mqutil.Logger.Printf("\n--- %s (row %d of %s)", t.Name, t.Row, t.Data)
+		fmt.Printf("\nRunning test case: %s (row %d of %s)\n", t.Name, t.Row, t.Data)
+	} else {
+		mqutil.Logger.Print("\n--- " + t.Name)
+		fmt.Printf("\nRunning test case: %s\n", t.Name)
+	}
 	err := t.ResolveParameters(tc)
 	if err != nil {
 		fmt.Printf("... Fail\n... %s\n", err.Error())
 		return err
 	}
+	if t.negative != nil {
+		fmt.Printf("... sending invalid input: %s\n", t.negative.reason)
+		t.negative.apply(t)
+	}
 
-	req := resty.R()
-	if len(tc.ApiToken) > 0 {
-		req.SetAuthToken(tc.ApiToken)
-	} else if len(tc.Username) > 0 {
-		req.SetBasicAuth(tc.Username, tc.Password)
+	req := tc.R()
+	oauth2, err := t.setCredentials(tc, req)
+	if err != nil {
+		fmt.Printf("... Fail\n... %s\n", err.Error())
+		return err
 	}
+	t.SetHeaders(tc.plan, req)
 
-	path := GetBaseURL(t.db.Swagger) + t.SetRequestParameters(req)
+	baseURL := tc.plan.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = GetBaseURL(t.db.Swagger)
+	}
+	requestPath := t.SetRequestParameters(req)
+	path := baseURL + requestPath
+	if tc.plan.SigV4 && !t.Anonymous {
+		err = t.SignSigV4(tc.plan, req, path, time.Now())
+		if err != nil {
+			fmt.Printf("... Fail\n... %s\n", err.Error())
+			return err
+		}
+	}
+	if err = t.beforeRequest(tc.plan, req, path); err != nil {
+		fmt.Printf("... Fail\n... %s\n", err.Error())
+		return err
+	}
 	var resp *resty.Response
 
-	t.startTime = time.Now()
-	switch t.Method {
-	case mqswag.MethodGet:
-		resp, err = req.Get(path)
-	case mqswag.MethodPost:
-		resp, err = req.Post(path)
-	case mqswag.MethodPut:
-		resp, err = req.Put(path)
-	case mqswag.MethodDelete:
-		resp, err = req.Delete(path)
-	case mqswag.MethodPatch:
-		resp, err = req.Patch(path)
-	case mqswag.MethodHead:
-		resp, err = req.Head(path)
-	case mqswag.MethodOptions:
-		resp, err = req.Options(path)
-	default:
-		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("Unknown method in test %s: %v", t.Name, t.Method))
+	if t.IsSerial() {
+		unlock := tc.plan.LockOperation(t.Method, t.Path)
+		defer unlock()
+	}
+
+	callPath := path
+	if tc.plan.dryRun != nil {
+		t.recordRequest(req, path)
+		t.printDryRun(t.request)
+		t.Notes = append(t.Notes, NoteDryRun)
+		callPath = tc.plan.dryRun.BaseURL() + requestPath
+	}
+
+	for retried := false; ; retried = true {
+		resp, err = t.callWithRetries(tc, req, callPath)
+		if !t.knownMethod() {
+			return err
+		}
+		if retried || !oauth2 || !t.retryWithNewToken(tc, req, resp, err) {
+			break
+		}
 	}
 	t.stopTime = time.Now()
+	t.StartedAt = t.startTime.UTC().Format(time.RFC3339Nano)
+	t.EndedAt = t.stopTime.UTC().Format(time.RFC3339Nano)
+	t.LatencyMs = float64(t.latency().Nanoseconds()/int64(time.Microsecond)) / 1000
 	fmt.Printf("... call completed: %f seconds\n", t.stopTime.Sub(t.startTime).Seconds())
+	t.recordSizes(req, resp)
+	if len(tc.plan.DumpRequests) > 0 || len(tc.plan.PostmanFile) > 0 {
+		if tc.plan.dryRun == nil {
+			// The dry run recorded the request before sending it to the stub server.
+			t.recordRequest(req, path)
+		}
+		if len(tc.plan.DumpRequests) > 0 {
+			tc.plan.DumpRequest(t)
+		}
+	}
 
-	if err != nil {
+	if err == nil && tc.plan.dryRun == nil && t.pollsAsync(tc.plan, resp) {
+		resp, err = t.PollAsync(tc, resp, path)
+		if err != nil {
+			fmt.Printf("... Fail\n... %s\n", err.Error())
+			return err
+		}
+	}
+
+	if tooLarge := tc.plan.responseTooLarge(err); tooLarge != nil {
+		t.Notes = append(t.Notes, NoteResponseTooLarge)
+		t.err = tooLarge
+	} else if err != nil && timedOut(err) && tc.plan.Timeout > 0 {
+		t.Notes = append(t.Notes, NoteTimeout)
+		t.err = mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf(
+			"=== test failed, no response within the %v timeout ===", tc.plan.Timeout))
+	} else if err != nil && t.hasNote(NoteRetryBudget) {
+		t.err = mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf(
+			"=== test failed, not retried as the retries couldn't finish before the deadline: %s ===", err.Error()))
+	} else if err != nil {
 		t.err = mqutil.NewError(mqutil.ErrHttp, err.Error())
 	} else {
-		mqutil.Logger.Print(resp.Status())
-		mqutil.Logger.Println(string(resp.Body()))
+		mqutil.Debugf("%s\n%s", resp.Status(), string(resp.Body()))
+		if err = t.afterResponse(tc.plan, resp); err != nil {
+			fmt.Printf("... Fail\n... %s\n", err.Error())
+			return err
+		}
+	}
+	if t.negative != nil {
+		if tc.plan.dryRun != nil {
+			// The stub server accepts anything.
+			return t.err
+		}
+		return t.checkRejected(resp)
 	}
 	err = t.ProcessResult(resp)
+	if err == nil && tc.plan.dryRun == nil {
+		err = t.checkLatency(tc.plan)
+	}
+	if err == nil && tc.plan.MaxPages > 1 && t.Method == mqswag.MethodGet && tc.plan.dryRun == nil &&
+		resp.StatusCode() >= 200 && resp.StatusCode() < 300 {
+		if body, decodeErr := DecodeJSON(resp.Body()); decodeErr == nil {
+			t.followPages(tc, baseURL, path, resp, body)
+		}
+	}
+	if err == nil && len(tc.plan.CORSOrigin) > 0 && tc.plan.dryRun == nil {
+		err = t.CheckCORS(tc.plan, path)
+	}
 	return err
 }
 
@@ -845,6 +1206,8 @@ func (t *Test) ResolveParameters(tc *TestSuite) error {
 
 	// There can be parameters at the path level. We merge these with the operation parameters.
 	t.op.Parameters = ParamsAdd(t.op.Parameters, pathItem.Parameters)
+	t.resolveContentType(tc.plan)
+	t.applyBodyExample()
 
 	t.tag = mqswag.GetMeqaTag(t.op.Description)
 
@@ -852,6 +1215,7 @@ func (t *Test) ResolveParameters(tc *TestSuite) error {
 	var globalParamsMap map[string]interface{}
 	var err error
 	var genParam interface{}
+	generatedPathParams := make(map[string]bool)
 	for _, params := range t.op.Parameters {
 		fmt.Printf("        %s (in %s): ", params.Name, params.In)
 		if params.In == "body" {
@@ -927,11 +1291,22 @@ func (t *Test) ResolveParameters(tc *TestSuite) error {
 			}
 			genParam, err = t.GenerateParameter(&params, t.db)
 			paramsMap[params.Name] = genParam
+			if params.In == "path" {
+				generatedPathParams[params.Name] = true
+			}
 		}
 		if err != nil {
 			return err
 		}
 	}
+
+	// The objects we picked may have been created under other path parameters (e.g.
+	// /tenants/{tenantId}/users/{userId}). Use those for the path parameters we generated.
+	for k, v := range t.storedPathParams {
+		if generatedPathParams[k] && v != nil {
+			t.PathParams[k] = v
+		}
+	}
 	return nil
 }
 
@@ -961,9 +1336,17 @@ func (t *Test) GenerateParameter(paramSpec *spec.Parameter, db *mqswag.DB) (inte
 	if paramSpec.Schema != nil {
 		return t.GenerateSchema("", tag, paramSpec.Schema, db, 3)
 	}
+	if paramSpec.Type == "file" {
+		return t.generateFile(paramSpec)
+	}
+	if paramSpec.AllowEmptyValue && !paramSpec.Required && rand.Intn(2) == 0 {
+		// Flag style parameter, e.g. ?debug
+		fmt.Print("empty\n")
+		return "", nil
+	}
 	if len(paramSpec.Enum) != 0 {
 		fmt.Print("enum\n")
-		return generateEnum(paramSpec.Enum)
+		return generateEnum(paramSpec.Enum, paramSpec.Type == gojsonschema.TYPE_INTEGER)
 	}
 	if len(paramSpec.Type) == 0 {
 		return nil, mqutil.NewError(mqutil.ErrInvalid, "Parameter doesn't have type")
@@ -981,6 +1364,17 @@ func (t *Test) GenerateParameter(paramSpec *spec.Parameter, db *mqswag.DB) (inte
 	return t.generateByType(schema, paramSpec.Name, tag, paramSpec, true)
 }
 
+// skipSeeded returns the objects of the class that didn't come from the seed file.
+func (t *Test) skipSeeded(className string, objects []interface{}) []interface{} {
+	var result []interface{}
+	for _, obj := range objects {
+		if !t.db.IsSeeded(className, obj) {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
 // Two ways to get to generateByType
 // 1) directly called from GenerateParameter, now we know the type is a parameter, and we want to add to comparison
 // 2) called at bottom level, here we know the object will be added to comparison and not the type primitives.
@@ -1006,8 +1400,15 @@ func (t *Test) generateByType(s *spec.Schema, prefix string, parentTag *mqswag.M
 			if len(ar) == 0 {
 				ar = t.db.Find(tag.Class, nil, nil, mqswag.MatchAlways, 5)
 			}
+			if t.Method == mqswag.MethodDelete {
+				// The seeded objects are there for the other tests to use, we never delete them.
+				ar = t.skipSeeded(tag.Class, ar)
+			}
 			if len(ar) > 0 {
 				obj := ar[rand.Intn(len(ar))].(map[string]interface{})
+				if paramSpec.In == "path" {
+					t.storedPathParams = mqutil.MapAdd(t.storedPathParams, t.db.GetPathParams(tag.Class, obj))
+				}
 				comp := &Comparison{obj, make(map[string]interface{}), nil, (*spec.Schema)(t.db.GetSchema(tag.Class))}
 				comp.oldUsed[tag.Property] = comp.old[tag.Property]
 				t.comparisons[tag.Class] = append(t.comparisons[tag.Class], comp)
@@ -1033,9 +1434,20 @@ func (t *Test) generateByType(s *spec.Schema, prefix string, parentTag *mqswag.M
 		case gojsonschema.TYPE_NUMBER:
 			result, err = generateFloat(s)
 		case gojsonschema.TYPE_STRING:
-			result, err = generateString(s, prefix)
+			fake, ok := "", false
+			if t.suite != nil && t.suite.plan != nil {
+				fake, ok = t.suite.plan.FakeString(s, prefix)
+			}
+			if ok {
+				result = fake
+			} else {
+				result, err = generateString(s, prefix)
+			}
 		case "file":
-			return nil, errors.New("can not automatically upload a file, parameter of file type must be manually set\n")
+			if paramSpec != nil {
+				return t.generateFile(paramSpec)
+			}
+			return nil, errors.New("a file can only be uploaded as a form parameter, not in a body")
 		}
 		if result != nil && err == nil {
 			t.AddBasicComparison(tag, paramSpec, result)
@@ -1056,15 +1468,27 @@ func RandomTime(t time.Time, r time.Duration) time.Time {
 // no specified pattern in the swagger.json
 func generateString(s *spec.Schema, prefix string) (string, error) {
 	if s.Format == "date-time" {
-		t := RandomTime(time.Now(), time.Hour*24*30)
+		t := RandomTime(randomTimeBase(), time.Hour*24*30)
 		return t.Format(time.RFC3339), nil
 	}
 	if s.Format == "date" {
-		t := RandomTime(time.Now(), time.Hour*24*30)
+		t := RandomTime(randomTimeBase(), time.Hour*24*30)
 		return t.Format("2006-01-02"), nil
 	}
 	if s.Format == "uuid" {
-		return uuid.NewV4().String(), nil
+		return randomUUID(), nil
+	}
+	if s.Format == "email" {
+		return fmt.Sprintf("%s%d@example.com", asciiName(prefix), rand.Intn(10000)), nil
+	}
+	if s.Format == "hostname" {
+		return fmt.Sprintf("%s%d.example.com", asciiName(prefix), rand.Intn(10000)), nil
+	}
+	if s.Format == "ipv4" {
+		return randomIPv4(), nil
+	}
+	if s.Format == "ipv6" {
+		return randomIPv6(), nil
 	}
 
 	// If no pattern is specified, we use the field name + some numbers as pattern
@@ -1147,12 +1571,37 @@ func generateInt(s *spec.Schema) (int64, error) {
 	if s.Minimum != nil && i <= int64(*s.Minimum) {
 		i++
 	}
+	if s.Maximum != nil && float64(i) > *s.Maximum {
+		i = int64(math.Floor(*s.Maximum))
+	}
 	return i, nil
 }
 
+// depthExceeded returns whether we are nested deeper than the plan's MaxDepth.
+func (t *Test) depthExceeded() bool {
+	return t.suite != nil && t.suite.plan.MaxDepth > 0 && t.depth > t.suite.plan.MaxDepth
+}
+
 func (t *Test) generateArray(name string, parentTag *mqswag.MeqaTag, schema *spec.Schema, db *mqswag.DB, level int) (interface{}, error) {
+	t.depth++
+	defer func() { t.depth-- }()
+	if t.depthExceeded() {
+		return []interface{}{}, nil
+	}
+
 	var numItems int
-	if schema.MaxItems != nil || schema.MinItems != nil {
+	if t.suite != nil && t.suite.plan.Fanout > 0 {
+		// The fanout is fixed, but it still has to honor the schema's limits.
+		numItems = t.suite.plan.Fanout
+		if schema.MaxItems != nil && numItems > int(*schema.MaxItems) {
+			numItems = int(*schema.MaxItems)
+		}
+		if schema.MinItems != nil && numItems < int(*schema.MinItems) {
+			numItems = int(*schema.MinItems)
+		}
+		// The first entry is generated separately below.
+		numItems--
+	} else if schema.MaxItems != nil || schema.MinItems != nil {
 		var maxItems int = 10
 		if schema.MaxItems != nil {
 			maxItems = int(*schema.MaxItems)
@@ -1175,7 +1624,7 @@ func (t *Test) generateArray(name string, parentTag *mqswag.MeqaTag, schema *spe
 	} else {
 		numItems = rand.Intn(10)
 	}
-	if numItems <= 0 {
+	if numItems <= 0 && (t.suite == nil || t.suite.plan.Fanout <= 0) {
 		numItems = 1
 	}
 
@@ -1214,6 +1663,10 @@ func (t *Test) generateArray(name string, parentTag *mqswag.MeqaTag, schema *spe
 		return nil
 	}
 
+	if t.suite != nil && t.suite.plan.ArrayVariety && len(itemSchema.Enum) > 0 {
+		return t.generateEnumArray(schema, itemSchema, level), nil
+	}
+
 	// we only print one entry
 	err := generateOneEntry()
 	if err != nil {
@@ -1226,10 +1679,19 @@ func (t *Test) generateArray(name string, parentTag *mqswag.MeqaTag, schema *spe
 			return nil, err
 		}
 	}
+	if t.suite != nil && t.suite.plan.ArrayVariety && len(ar) > 1 {
+		err = t.varyKeyField(ar, itemSchema, db)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return ar, nil
 }
 
 func (t *Test) generateObject(name string, parentTag *mqswag.MeqaTag, schema *spec.Schema, db *mqswag.DB, level int) (interface{}, error) {
+	t.depth++
+	defer func() { t.depth-- }()
+
 	obj := make(map[string]interface{})
 	var spaces string
 	var nextLevel int
@@ -1240,7 +1702,18 @@ func (t *Test) generateObject(name string, parentTag *mqswag.MeqaTag, schema *sp
 	if level != 0 {
 		fmt.Println("")
 	}
-	for k, v := range schema.Properties {
+	// The properties are generated in order, so that a seeded run draws the same values for them.
+	keys := make([]string, 0, len(schema.Properties))
+	for k := range schema.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := schema.Properties[k]
+		if t.depthExceeded() && (v.Type.Contains(gojsonschema.TYPE_OBJECT) || v.Type.Contains(gojsonschema.TYPE_ARRAY)) {
+			// Too deep, only fill in the primitive fields.
+			continue
+		}
 		if level != 0 {
 			fmt.Printf("%s%s . ", spaces, k)
 		}
@@ -1303,7 +1776,7 @@ func (t *Test) GenerateSchema(name string, parentTag *mqswag.MeqaTag, schema *sp
 		if level != 0 {
 			fmt.Print("enum\n")
 		}
-		return generateEnum(schema.Enum)
+		return generateEnum(schema.Enum, len(schema.Type) > 0 && schema.Type[0] == gojsonschema.TYPE_INTEGER)
 	}
 
 	if len(schema.AllOf) > 0 {
@@ -1352,6 +1825,12 @@ func (t *Test) GenerateSchema(name string, parentTag *mqswag.MeqaTag, schema *sp
 	return t.generateByType(schema, name, tag, nil, level != 0)
 }
 
-func generateEnum(e []interface{}) (interface{}, error) {
-	return e[rand.Intn(len(e))], nil
+// generateEnum picks one of the enum values. The numbers in the spec are decoded as float64, for integer
+// enums we turn them back into integers so that they are never sent with a decimal point.
+func generateEnum(e []interface{}, integer bool) (interface{}, error) {
+	v := e[rand.Intn(len(e))]
+	if f, ok := v.(float64); ok && integer && f == math.Trunc(f) {
+		return int64(f), nil
+	}
+	return v, nil
 }
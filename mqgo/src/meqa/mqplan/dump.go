@@ -0,0 +1,146 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+const redacted = "REDACTED"
+
+// The header, query and body field names containing these are considered sensitive.
+var sensitiveNames = []string{"authorization", "cookie", "password", "passwd", "secret", "token", "apikey", "api_key", "api-key"}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactObject returns a copy of the object with the values of the sensitive fields replaced.
+func redactObject(obj interface{}) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, v := range o {
+			if isSensitive(k) {
+				m[k] = redacted
+			} else {
+				m[k] = redactObject(v)
+			}
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(o))
+		for i, v := range o {
+			a[i] = redactObject(v)
+		}
+		return a
+	}
+	return obj
+}
+
+func redactHeader(header http.Header) map[string][]string {
+	h := make(map[string][]string)
+	for k, v := range header {
+		if isSensitive(k) {
+			h[k] = []string{redacted}
+		} else {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || len(u.RawQuery) == 0 {
+		return rawURL
+	}
+	query := u.Query()
+	for k := range query {
+		if isSensitive(k) {
+			query.Set(k, redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// requestRecord is one line in the request dump file.
+type requestRecord struct {
+	Suite     string              `json:"suite"`
+	Test      string              `json:"test"`
+	Method    string              `json:"method"`
+	URL       string              `json:"url"`
+	Headers   map[string][]string `json:"headers"`
+	Body      interface{}         `json:"body,omitempty"`
+	StartedAt string              `json:"startedAt"`
+	EndedAt   string              `json:"endedAt"`
+}
+
+// recordRequest records the resolved request of the test, with the sensitive values redacted.
+func (t *Test) recordRequest(req *resty.Request, path string) {
+	record := &requestRecord{Test: t.Name, Method: strings.ToUpper(t.Method), StartedAt: t.StartedAt, EndedAt: t.EndedAt}
+	if t.suite != nil {
+		record.Suite = t.suite.Name
+	}
+	if req.RawRequest != nil {
+		// The request went out, use what's actually sent.
+		record.URL = req.RawRequest.URL.String()
+		record.Headers = redactHeader(req.RawRequest.Header)
+	} else {
+		record.URL = path
+		if len(req.QueryParam) > 0 {
+			record.URL += "?" + req.QueryParam.Encode()
+		}
+		record.Headers = redactHeader(req.Header)
+	}
+	record.URL = redactURL(record.URL)
+	if t.BodyParams != nil {
+		record.Body = redactObject(t.BodyParams)
+	} else if len(t.FormParams) > 0 {
+		record.Body = redactObject(t.FormParams)
+	}
+	t.request = record
+}
+
+// DumpRequest appends the recorded request of the test to the plan's request dump file.
+func (plan *TestPlan) DumpRequest(t *Test) {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if plan.dumpFile == nil {
+		f, err := os.Create(plan.DumpRequests)
+		if err != nil {
+			mqutil.Errorf("can't create request dump file %s: %s", plan.DumpRequests, err.Error())
+			plan.DumpRequests = ""
+			return
+		}
+		plan.dumpFile = f
+	}
+
+	line, err := json.Marshal(t.request)
+	if err != nil {
+		mqutil.Warnf("can't dump request of %s: %s", t.Name, err.Error())
+		return
+	}
+	plan.dumpFile.Write(append(line, '\n'))
+}
+
+// CloseRequestDump closes the request dump file if one is open.
+func (plan *TestPlan) CloseRequestDump() {
+	if plan.dumpFile != nil {
+		plan.dumpFile.Close()
+		plan.dumpFile = nil
+	}
+}
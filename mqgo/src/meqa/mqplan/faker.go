@@ -0,0 +1,268 @@
+package mqplan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v2"
+
+	"meqa/mqutil"
+)
+
+// Faker categories.
+const (
+	FakeNone      = "none" // don't fake, use the regular random generation
+	FakeEmail     = "email"
+	FakeFirstName = "firstName"
+	FakeLastName  = "lastName"
+	FakeName      = "name"
+	FakeUsername  = "username"
+	FakePhone     = "phone"
+	FakeStreet    = "street"
+	FakeCity      = "city"
+	FakeCountry   = "country"
+	FakeZip       = "zip"
+	FakeCompany   = "company"
+	FakeURL       = "url"
+	FakeIPv4      = "ipv4"
+	FakeUUID      = "uuid"
+	FakeDate      = "date"
+	FakeDateTime  = "dateTime"
+)
+
+const DefaultLocale = "en"
+
+// FakerRule maps the string fields whose name (or format) matches to a faker category. When Format
+// is set the rule matches the schema's format instead of the field name. Fields with a format are
+// only matched by format rules.
+type FakerRule struct {
+	Match    string `yaml:"match,omitempty"`
+	Format   string `yaml:"format,omitempty"`
+	Category string `yaml:"category"`
+
+	re *regexp.Regexp
+}
+
+func (r *FakerRule) matches(name string, format string) bool {
+	if len(r.Format) > 0 {
+		return r.Format == format
+	}
+	return len(format) == 0 && r.re != nil && r.re.MatchString(name)
+}
+
+// The default rules, tried in order. The field name has "_" and "-" removed before matching.
+var defaultFakerRules = []*FakerRule{
+	{Format: "email", Category: FakeEmail},
+	{Match: "email", Category: FakeEmail},
+	{Match: "^(first|given)name$", Category: FakeFirstName},
+	{Match: "^(last|sur|family)name$", Category: FakeLastName},
+	{Match: "^(user|login)(name)?$", Category: FakeUsername},
+	{Match: "^(full)?name$", Category: FakeName},
+	{Match: "phone|mobile|^tel", Category: FakePhone},
+	{Match: "street|^address(line)?\\d?$", Category: FakeStreet},
+	{Match: "city|town", Category: FakeCity},
+	{Match: "country", Category: FakeCountry},
+	{Match: "zip|post(al)?code", Category: FakeZip},
+	{Match: "company|organization", Category: FakeCompany},
+	{Match: "url$|^uri$|website|homepage", Category: FakeURL},
+	{Match: "^ip$|ip(v4)?address|^ipv4$", Category: FakeIPv4},
+	{Match: "uuid$|guid$", Category: FakeUUID},
+	{Match: "(created|updated|modified|deleted)(at|on|time)$|timestamp", Category: FakeDateTime},
+	{Match: "(^|birth|start|end|due|expiry|expiration|release)date$|^dob$|birthday", Category: FakeDate},
+}
+
+// LoadFakerRules reads a list of faker rules from a yaml file, e.g. "- {match: nick, category: firstName}".
+// The rules take priority over the default ones.
+func LoadFakerRules(path string) ([]*FakerRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []*FakerRule
+	err = yaml.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if len(r.Match) > 0 {
+			r.re, err = regexp.Compile("(?i)" + r.Match)
+			if err != nil {
+				return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid faker rule %s: %s", r.Match, err.Error()))
+			}
+		}
+	}
+	return rules, nil
+}
+
+func init() {
+	for _, r := range defaultFakerRules {
+		if len(r.Match) > 0 {
+			r.re = regexp.MustCompile("(?i)" + r.Match)
+		}
+	}
+}
+
+type localeData struct {
+	firstNames []string
+	lastNames  []string
+	streets    []string
+	cities     []string
+	country    string
+	phone      string // '#' is replaced by a random digit
+	zip        string
+	companies  []string
+	domain     string
+}
+
+var locales = map[string]*localeData{
+	"en": {
+		firstNames: []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Susan"},
+		lastNames:  []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Taylor", "Clark"},
+		streets:    []string{"Main Street", "Oak Avenue", "Maple Drive", "Park Road", "Cedar Lane", "Elm Street"},
+		cities:     []string{"Springfield", "Portland", "Austin", "Denver", "Boston", "Seattle", "Madison"},
+		country:    "United States",
+		phone:      "+1 ###-###-####",
+		zip:        "#####",
+		companies:  []string{"Acme Inc.", "Globex Corp.", "Initech LLC", "Umbrella Co.", "Stark Industries"},
+		domain:     "example.com",
+	},
+	"de": {
+		firstNames: []string{"Lukas", "Anna", "Leon", "Lena", "Felix", "Marie", "Jonas", "Sophie", "Paul", "Laura"},
+		lastNames:  []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker"},
+		streets:    []string{"Hauptstraße", "Bahnhofstraße", "Gartenweg", "Schillerstraße", "Lindenallee"},
+		cities:     []string{"Berlin", "Hamburg", "München", "Köln", "Frankfurt", "Stuttgart", "Leipzig"},
+		country:    "Deutschland",
+		phone:      "+49 ### #######",
+		zip:        "#####",
+		companies:  []string{"Muster GmbH", "Beispiel AG", "Technik KG", "Nordwerk GmbH"},
+		domain:     "beispiel.de",
+	},
+	"fr": {
+		firstNames: []string{"Louis", "Camille", "Gabriel", "Léa", "Jules", "Chloé", "Hugo", "Manon", "Arthur", "Inès"},
+		lastNames:  []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand"},
+		streets:    []string{"rue de la Paix", "avenue Victor Hugo", "rue du Moulin", "boulevard Voltaire"},
+		cities:     []string{"Paris", "Lyon", "Marseille", "Toulouse", "Nantes", "Bordeaux", "Lille"},
+		country:    "France",
+		phone:      "+33 # ## ## ## ##",
+		zip:        "#####",
+		companies:  []string{"Exemple SA", "Société Durand", "Atelier Martin SARL", "Groupe Lumière"},
+		domain:     "exemple.fr",
+	},
+}
+
+// HasLocale checks whether the faker has data for the locale.
+func HasLocale(locale string) bool {
+	return locales[locale] != nil
+}
+
+func pick(list []string) string {
+	return list[rand.Intn(len(list))]
+}
+
+func fillDigits(format string) string {
+	b := []byte(format)
+	for i := range b {
+		if b[i] == '#' {
+			b[i] = byte('0' + rand.Intn(10))
+		}
+	}
+	return string(b)
+}
+
+// randomIPv4 returns a public looking ipv4 address.
+func randomIPv4() string {
+	return fmt.Sprintf("%d.%d.%d.%d", rand.Intn(223)+1, rand.Intn(256), rand.Intn(256), rand.Intn(254)+1)
+}
+
+// randomIPv6 returns an ipv6 address in the documentation prefix 2001:db8::/32.
+func randomIPv6() string {
+	return fmt.Sprintf("2001:db8:%x:%x:%x:%x:%x:%x", rand.Intn(0x10000), rand.Intn(0x10000), rand.Intn(0x10000),
+		rand.Intn(0x10000), rand.Intn(0x10000), rand.Intn(0x10000))
+}
+
+// asciiName makes a name usable in user names and email addresses.
+func asciiName(name string) string {
+	var b []byte
+	for _, c := range strings.ToLower(name) {
+		if c >= 'a' && c <= 'z' {
+			b = append(b, byte(c))
+		}
+	}
+	if len(b) == 0 {
+		return "user"
+	}
+	return string(b)
+}
+
+// fakeCategory finds the faker category for the field.
+func (plan *TestPlan) fakeCategory(name string, format string) string {
+	name = strings.NewReplacer("_", "", "-", "").Replace(name)
+	for _, rules := range [][]*FakerRule{plan.FakerRules, defaultFakerRules} {
+		for _, r := range rules {
+			if r.matches(name, format) {
+				return r.Category
+			}
+		}
+	}
+	return FakeNone
+}
+
+// FakeString generates a plausible value for a string field based on its name and format. It returns false
+// if the field doesn't map to a faker category, in which case we fall back to random strings.
+func (plan *TestPlan) FakeString(s *spec.Schema, name string) (string, bool) {
+	if len(s.Pattern) > 0 || len(plan.Locale) == 0 {
+		return "", false
+	}
+	data := locales[plan.Locale]
+	if data == nil {
+		data = locales[DefaultLocale]
+	}
+	first, last := pick(data.firstNames), pick(data.lastNames)
+	var str string
+	switch plan.fakeCategory(name, s.Format) {
+	case FakeEmail:
+		str = fmt.Sprintf("%s.%s%d@%s", asciiName(first), asciiName(last), rand.Intn(10000), data.domain)
+	case FakeFirstName:
+		str = first
+	case FakeLastName:
+		str = last
+	case FakeName:
+		str = first + " " + last
+	case FakeUsername:
+		str = fmt.Sprintf("%s%s%d", asciiName(first), asciiName(last), rand.Intn(10000))
+	case FakePhone:
+		str = fillDigits(data.phone)
+	case FakeStreet:
+		str = fmt.Sprintf("%d %s", rand.Intn(200)+1, pick(data.streets))
+	case FakeCity:
+		str = pick(data.cities)
+	case FakeCountry:
+		str = data.country
+	case FakeZip:
+		str = fillDigits(data.zip)
+	case FakeCompany:
+		str = pick(data.companies)
+	case FakeURL:
+		str = fmt.Sprintf("https://www.%s/%s%d", data.domain, asciiName(last), rand.Intn(10000))
+	case FakeIPv4:
+		str = randomIPv4()
+	case FakeUUID:
+		str = randomUUID()
+	case FakeDate:
+		str = RandomTime(randomTimeBase(), time.Hour*24*30).Format("2006-01-02")
+	case FakeDateTime:
+		str = RandomTime(randomTimeBase(), time.Hour*24*30).Format(time.RFC3339)
+	default:
+		return "", false
+	}
+	length := int64(len([]rune(str)))
+	if (s.MaxLength != nil && length > *s.MaxLength) || (s.MinLength != nil && length < *s.MinLength) {
+		return "", false
+	}
+	return str, true
+}
@@ -0,0 +1,121 @@
+package mqplan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqutil"
+)
+
+// The note on tests whose suite was left out by the -t, -tag and -match filters.
+const NoteFiltered = "filtered"
+
+// operation returns the swagger operation the test calls.
+func (plan *TestPlan) operation(t *Test) *spec.Operation {
+	if t.op != nil || plan.swagger == nil {
+		return t.op
+	}
+	if pathItem, ok := plan.swagger.Paths.Paths[t.Path]; ok {
+		return GetOperationByMethod(&pathItem, strings.ToLower(t.Method))
+	}
+	return nil
+}
+
+// hasTag returns whether the suite, or a suite it refers to, calls an operation with one of the tags.
+func (plan *TestPlan) hasTag(tc *TestSuite, tags []string, visited map[*TestSuite]bool) bool {
+	if visited[tc] {
+		return false
+	}
+	visited[tc] = true
+	for _, test := range tc.Tests {
+		if len(test.Ref) > 0 {
+			if ref, ok := plan.SuiteMap[test.Ref]; ok && plan.hasTag(ref, tags, visited) {
+				return true
+			}
+			continue
+		}
+		op := plan.operation(test)
+		if op == nil {
+			continue
+		}
+		for _, opTag := range op.Tags {
+			for _, tag := range tags {
+				if opTag == tag {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// selects returns whether the suite passes the plan's tag and name pattern filters.
+func (plan *TestPlan) selects(tc *TestSuite) bool {
+	if plan.SuiteMatch != nil && !plan.SuiteMatch.MatchString(tc.Name) {
+		return false
+	}
+	return len(plan.SuiteTags) == 0 || plan.hasTag(tc, plan.SuiteTags, make(map[*TestSuite]bool))
+}
+
+// SelectSuites returns the names of the suites to run, in the plan's order, and the suites left out. The
+// names are the comma separated list of -t, or "all". A suite runs if it's in the list, passes the
+// plan's SuiteTags and SuiteMatch filters, and failed in the result file of -rerun.
+func (plan *TestPlan) SelectSuites(names string) ([]string, []*TestSuite, error) {
+	listed := make(map[string]bool)
+	if names != "all" {
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 0 {
+				continue
+			}
+			if _, ok := plan.SuiteMap[name]; !ok {
+				return nil, nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("The following test suite is not found: %s", name))
+			}
+			listed[name] = true
+		}
+	}
+	var selected []string
+	var filtered []*TestSuite
+	for _, tc := range plan.SuiteList {
+		if (len(listed) == 0 || listed[tc.Name]) && plan.selects(tc) && plan.reruns(tc) {
+			selected = append(selected, tc.Name)
+		} else {
+			filtered = append(filtered, tc)
+		}
+	}
+	return selected, filtered, nil
+}
+
+// SkipFiltered records the tests of the suites left out by the filters as skipped, so that the result
+// file still lists every test of the plan.
+func (plan *TestPlan) SkipFiltered(suites []*TestSuite) {
+	for _, tc := range suites {
+		for _, test := range tc.Tests {
+			if len(test.Ref) != 0 || test.Name == MeqaInit {
+				continue
+			}
+			skipped := test.Duplicate()
+			skipped.Notes = []string{plan.filteredNote(tc)}
+			// Outside of Run the suite has no root, so the test is its own suite's.
+			skipped.root = tc
+			plan.mutex.Lock()
+			plan.resultList = append(plan.resultList, skipped)
+			plan.mutex.Unlock()
+		}
+	}
+}
+
+// ParseSuiteMatch compiles the -match pattern of the suite names, nil if there is none.
+func ParseSuiteMatch(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid -match pattern %s: %s", pattern, err.Error()))
+	}
+	return re, nil
+}
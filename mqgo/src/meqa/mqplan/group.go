@@ -0,0 +1,48 @@
+package mqplan
+
+import (
+	"fmt"
+
+	"meqa/mqutil"
+)
+
+// The ways the tests can be grouped into suites in the result file. By default they all go into one suite.
+const (
+	GroupBySuite  = "suite"
+	GroupByTag    = "tag"
+	GroupByStatus = "status"
+)
+
+// CheckGroupBy returns an error if the grouping isn't one we support.
+func CheckGroupBy(groupBy string) error {
+	switch groupBy {
+	case "", GroupBySuite, GroupByTag, GroupByStatus:
+		return nil
+	}
+	return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown grouping %s, expecting suite, tag or status", groupBy))
+}
+
+// resultGroup returns the name of the result suite the test goes into when grouped by groupBy.
+func (plan *TestPlan) resultGroup(t *Test, groupBy string) string {
+	switch groupBy {
+	case GroupBySuite:
+		if t.suite != nil {
+			return t.suite.Name
+		}
+	case GroupByTag:
+		op := plan.operation(t)
+		if op != nil && len(op.Tags) > 0 {
+			return op.Tags[0]
+		}
+		return "untagged"
+	case GroupByStatus:
+		if t.skipped() {
+			return "skipped"
+		}
+		if t.err != nil {
+			return "failed"
+		}
+		return "passed"
+	}
+	return ""
+}
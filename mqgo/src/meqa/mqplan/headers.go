@@ -0,0 +1,46 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/resty.v0"
+)
+
+// Headers implements flag.Value so the headers sent with every request can be set through repeated
+// -H "Name: Value". The environment variables in the values, like ${TENANT_ID}, are expanded.
+type Headers map[string]string
+
+func (headers *Headers) String() string {
+	var list []string
+	for name := range *headers {
+		list = append(list, name+": "+redacted)
+	}
+	return strings.Join(list, ",")
+}
+
+func (headers *Headers) Set(s string) error {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+		return fmt.Errorf("invalid header %s, the format is \"Name: Value\"", s)
+	}
+	if *headers == nil {
+		*headers = make(Headers)
+	}
+	name := http.CanonicalHeaderKey(strings.TrimSpace(kv[0]))
+	(*headers)[name] = os.ExpandEnv(strings.TrimSpace(kv[1]))
+	return nil
+}
+
+// SetHeaders sets the plan's custom headers on the request. They go after the credentials so they can
+// override the defaults, but the anonymous tests are still called without an Authorization header.
+func (t *Test) SetHeaders(plan *TestPlan, req *resty.Request) {
+	for name, value := range plan.Headers {
+		if t.Anonymous && name == "Authorization" {
+			continue
+		}
+		req.SetHeader(name, value)
+	}
+}
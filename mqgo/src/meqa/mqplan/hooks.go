@@ -0,0 +1,63 @@
+package mqplan
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// RequestHook is called with the request of each test before it's sent, e.g. to sign it. The request has
+// its method, url, headers and body set. An error fails the test.
+type RequestHook func(req *resty.Request) error
+
+// ResponseHook is called with the response of each test before it's checked. An error fails the test.
+type ResponseHook func(resp *resty.Response) error
+
+// BeforeRequest adds a hook that the tests call before sending their request, after the ones added before.
+func (plan *TestPlan) BeforeRequest(hook RequestHook) {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	plan.requestHooks = append(plan.requestHooks, hook)
+}
+
+// AfterResponse adds a hook that the tests call with their response, after the ones added before.
+func (plan *TestPlan) AfterResponse(hook ResponseHook) {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	plan.responseHooks = append(plan.responseHooks, hook)
+}
+
+// beforeRequest calls the request hooks on the test's request to the url.
+func (t *Test) beforeRequest(plan *TestPlan, req *resty.Request, url string) error {
+	plan.mutex.Lock()
+	hooks := plan.requestHooks
+	plan.mutex.Unlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+	// Resty only sets these when sending, the hooks need them to sign the request.
+	req.Method = strings.ToUpper(t.Method)
+	req.URL = url
+	for _, hook := range hooks {
+		if err := hook(req); err != nil {
+			return mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("=== test failed, the request hook failed: %s ===", err.Error()))
+		}
+	}
+	return nil
+}
+
+// afterResponse calls the response hooks on the test's response.
+func (t *Test) afterResponse(plan *TestPlan, resp *resty.Response) error {
+	plan.mutex.Lock()
+	hooks := plan.responseHooks
+	plan.mutex.Unlock()
+	for _, hook := range hooks {
+		if err := hook(resp); err != nil {
+			return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf("=== test failed, the response hook failed: %s ===", err.Error()))
+		}
+	}
+	return nil
+}
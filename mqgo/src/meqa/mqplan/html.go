@@ -0,0 +1,177 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"meqa/mqutil"
+)
+
+// The status of a test in the html report.
+const (
+	htmlPassed  = "passed"
+	htmlFailed  = "failed"
+	htmlSkipped = "skipped"
+)
+
+type htmlTest struct {
+	Name         string
+	Status       string
+	Time         string
+	Notes        string
+	Error        string
+	Request      string // the method and url
+	RequestBody  string
+	Response     string // the status line
+	ResponseBody string
+}
+
+type htmlSuite struct {
+	Name    string
+	Passed  int
+	Failed  int
+	Skipped int
+	Tests   []htmlTest
+}
+
+type htmlReport struct {
+	Timestamp string
+	BaseURL   string
+	Total     int
+	Passed    int
+	Failed    int
+	Skipped   int
+	Suites    []htmlSuite
+	Comment   string
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>meqa test report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+details { margin: 0.5em 0; }
+summary { cursor: pointer; font-weight: bold; }
+pre { background: #f6f6f6; padding: 6px; white-space: pre-wrap; word-break: break-all; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; }
+.skipped { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>meqa test report</h1>
+<p>Run at {{.Timestamp}} against {{.BaseURL}}</p>
+<table>
+<tr><th>Tests</th><th class="passed">Passed</th><th class="failed">Failed</th><th class="skipped">Skipped</th></tr>
+<tr><td>{{.Total}}</td><td class="passed">{{.Passed}}</td><td class="failed">{{.Failed}}</td><td class="skipped">{{.Skipped}}</td></tr>
+</table>
+{{range .Suites}}
+<details{{if .Failed}} open{{end}}>
+<summary>{{.Name}}: <span class="passed">{{.Passed}} passed</span>, <span class="failed">{{.Failed}} failed</span>, <span class="skipped">{{.Skipped}} skipped</span></summary>
+<table>
+<tr><th>Test</th><th>Status</th><th>Time (s)</th><th>Notes</th></tr>
+{{range .Tests}}
+<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Time}}</td><td>{{.Notes}}</td></tr>
+{{if .Error}}
+<tr><td colspan="4">
+<pre class="failed">{{.Error}}</pre>
+<pre>{{.Request}}{{if .RequestBody}}
+{{.RequestBody}}{{end}}</pre>
+{{if .Response}}<pre>{{.Response}}{{if .ResponseBody}}
+{{.ResponseBody}}{{end}}</pre>{{end}}
+</td></tr>
+{{end}}
+{{end}}
+</table>
+</details>
+{{end}}
+{{if .Comment}}<pre>{{.Comment}}</pre>{{end}}
+</body>
+</html>
+`))
+
+// htmlTestOf returns the row of the test in the html report. The request and response of the failed
+// tests are included, with the sensitive values redacted.
+func htmlTestOf(test *Test) htmlTest {
+	row := htmlTest{Name: test.Name, Status: htmlPassed, Time: junitTime(0), Notes: strings.Join(test.Notes, ", ")}
+	if !test.startTime.IsZero() && !test.stopTime.IsZero() {
+		row.Time = junitTime(test.stopTime.Sub(test.startTime).Seconds())
+	}
+	if test.skipped() {
+		row.Status = htmlSkipped
+	}
+	if test.err == nil {
+		return row
+	}
+	row.Status = htmlFailed
+	row.Error = test.err.Error()
+	row.Request = strings.ToUpper(test.Method) + " " + test.Path
+	if test.resp != nil && test.resp.Request != nil && len(test.resp.Request.URL) > 0 {
+		row.Request = strings.ToUpper(test.Method) + " " + redactURL(test.resp.Request.URL)
+	}
+	var body interface{}
+	if test.BodyParams != nil {
+		body = test.BodyParams
+	} else if len(test.FormParams) > 0 {
+		body = test.FormParams
+	}
+	if body != nil {
+		if data, err := json.MarshalIndent(redactObject(body), "", "  "); err == nil {
+			row.RequestBody = string(data)
+		}
+	}
+	if test.resp != nil {
+		row.Response = test.resp.Status()
+		row.ResponseBody = string(test.resp.Body())
+	}
+	return row
+}
+
+// WriteHTMLToFile writes the tests of the plan as a self-contained html report, with the counts of the
+// run and one collapsible section per test suite. The baseURL is the server the tests ran against.
+func (plan *TestPlan) WriteHTMLToFile(path string, baseURL string) error {
+	report := &htmlReport{BaseURL: baseURL, Comment: plan.comment}
+	var start time.Time
+	for _, testSuite := range plan.SuiteList {
+		suite := htmlSuite{Name: testSuite.Name}
+		for _, test := range testSuite.Tests {
+			if !test.startTime.IsZero() && (start.IsZero() || test.startTime.Before(start)) {
+				start = test.startTime
+			}
+			row := htmlTestOf(test)
+			switch row.Status {
+			case htmlFailed:
+				suite.Failed++
+			case htmlSkipped:
+				suite.Skipped++
+			default:
+				suite.Passed++
+			}
+			suite.Tests = append(suite.Tests, row)
+		}
+		report.Total += len(suite.Tests)
+		report.Passed += suite.Passed
+		report.Failed += suite.Failed
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	report.Timestamp = start.UTC().Format(time.RFC3339)
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, report); err != nil {
+		return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't write html report: %s", err.Error()))
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,150 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"meqa/mqutil"
+)
+
+// The formats of the result file.
+const (
+	ResultFormatYaml  = "yaml"
+	ResultFormatJUnit = "junit"
+	ResultFormatHTML  = "html"
+)
+
+// CheckResultFormat returns an error if the result file format isn't one we support.
+func CheckResultFormat(format string) error {
+	switch format {
+	case "", ResultFormatYaml, ResultFormatJUnit, ResultFormatHTML:
+		return nil
+	}
+	return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown result format %s, expecting yaml, junit or html", format))
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// junitFailureText returns the request and response of the failed test, with the sensitive values redacted.
+func (t *Test) junitFailureText() string {
+	var lines []string
+	var body interface{}
+	if t.BodyParams != nil {
+		body = t.BodyParams
+	} else if len(t.FormParams) > 0 {
+		body = t.FormParams
+	}
+	lines = append(lines, fmt.Sprintf("request: %s %s", strings.ToUpper(t.Method), t.Path))
+	if body != nil {
+		if data, err := json.MarshalIndent(redactObject(body), "", "  "); err == nil {
+			lines = append(lines, string(data))
+		}
+	}
+	if t.resp != nil {
+		lines = append(lines, "", "response: "+t.resp.Status())
+		if respBody := t.resp.Body(); len(respBody) > 0 {
+			lines = append(lines, string(respBody))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteJUnitToFile writes the tests of the plan as a junit xml report, one testsuite per test suite.
+func (plan *TestPlan) WriteJUnitToFile(path string) error {
+	report := &junitTestSuites{}
+	var total float64
+	for _, testSuite := range plan.SuiteList {
+		suite := junitTestSuite{Name: testSuite.Name}
+		if plan.RandSeed != 0 {
+			suite.Properties = []junitProperty{{Name: "randseed", Value: fmt.Sprint(plan.RandSeed)}}
+		}
+		var suiteTime float64
+		for _, test := range testSuite.Tests {
+			testCase := junitTestCase{Name: test.Name, ClassName: testSuite.Name}
+			if !test.startTime.IsZero() && !test.stopTime.IsZero() {
+				seconds := test.stopTime.Sub(test.startTime).Seconds()
+				testCase.Time = junitTime(seconds)
+				suiteTime += seconds
+			} else {
+				testCase.Time = junitTime(0)
+			}
+			if len(suite.Timestamp) == 0 && !test.startTime.IsZero() {
+				suite.Timestamp = test.startTime.UTC().Format("2006-01-02T15:04:05")
+			}
+			for _, note := range test.Notes {
+				if isSkipNote(note) {
+					testCase.Skipped = &junitSkipped{Message: note}
+					suite.Skipped++
+					break
+				}
+			}
+			if test.err != nil {
+				testCase.Failure = &junitFailure{Message: test.err.Error(), Type: "failure", Text: test.junitFailureText()}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		suite.Tests = len(suite.TestCases)
+		suite.Time = junitTime(suiteTime)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		total += suiteTime
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = junitTime(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't write junit report: %s", err.Error()))
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
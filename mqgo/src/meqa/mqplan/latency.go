@@ -0,0 +1,56 @@
+package mqplan
+
+import (
+	"fmt"
+	"time"
+
+	"meqa/mqutil"
+)
+
+// MaxLatencyExtension is the swagger extension on operations with a latency budget of their own, e.g.
+// "200ms". A number is in milliseconds. It overrides the plan's MaxLatency.
+const MaxLatencyExtension = "x-meqa-max-latency"
+
+// The note on the tests that failed because their call took longer than the latency budget.
+const NoteLatency = "latency-budget-exceeded"
+
+// latency returns how long the call of the test took, the last attempt's when it was retried.
+func (t *Test) latency() time.Duration {
+	if t.startTime.IsZero() || t.stopTime.IsZero() {
+		return 0
+	}
+	return t.stopTime.Sub(t.startTime)
+}
+
+// maxLatency returns the latency budget of the test's operation, 0 if there is none.
+func (t *Test) maxLatency(plan *TestPlan) time.Duration {
+	if t.op == nil {
+		return plan.MaxLatency
+	}
+	switch budget := t.op.Extensions[MaxLatencyExtension].(type) {
+	case string:
+		d, err := time.ParseDuration(budget)
+		if err == nil && d > 0 {
+			return d
+		}
+		mqutil.Warnf("invalid %s %s on %s %s, expecting a duration like 200ms", MaxLatencyExtension, budget, t.Method, t.Path)
+	case float64:
+		if budget > 0 {
+			return time.Duration(budget * float64(time.Millisecond))
+		}
+	}
+	return plan.MaxLatency
+}
+
+// checkLatency fails the test if its call took longer than the budget.
+func (t *Test) checkLatency(plan *TestPlan) error {
+	budget := t.maxLatency(plan)
+	latency := t.latency()
+	if budget <= 0 || latency <= budget {
+		return nil
+	}
+	fmt.Printf("... call took %v, the latency budget is %v. Fail\n", latency, budget)
+	t.Notes = append(t.Notes, NoteLatency)
+	return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+		"=== test failed, latency budget exceeded, the call took %v, the budget is %v ===", latency, budget))
+}
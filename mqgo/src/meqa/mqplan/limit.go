@@ -0,0 +1,66 @@
+package mqplan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"meqa/mqutil"
+)
+
+// The note on tests whose response was cut off at the plan's MaxResponseBytes.
+const NoteResponseTooLarge = "response-too-large"
+
+var errResponseTooLarge = errors.New(NoteResponseTooLarge)
+
+// limitedConn fails the reads once more than limit bytes have been read from the connection.
+type limitedConn struct {
+	net.Conn
+	limit int64
+	read  int64
+}
+
+func (c *limitedConn) Read(b []byte) (int, error) {
+	if c.read > c.limit {
+		return 0, errResponseTooLarge
+	}
+	n, err := c.Conn.Read(b)
+	c.read += int64(n)
+	if c.read > c.limit {
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
+
+// limitedTransport returns a transport that stops reading a response past limit bytes. The connections
+// aren't reused, so that every connection carries only one response. As we count what's read from the
+// connection, the status line and the headers count toward the limit as well.
+func (plan *TestPlan) limitedTransport(limit int64) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy: plan.proxy(),
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &limitedConn{Conn: conn, limit: limit}, nil
+		},
+		TLSClientConfig:     plan.TLSConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   true,
+	}
+}
+
+// responseTooLarge turns the error of a call that hit the response size limit into a test failure.
+func (plan *TestPlan) responseTooLarge(err error) error {
+	if err == nil || plan.MaxResponseBytes <= 0 || !strings.Contains(err.Error(), errResponseTooLarge.Error()) {
+		return nil
+	}
+	return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf(
+		"=== test failed, %s, the response is over %d bytes ===", NoteResponseTooLarge, plan.MaxResponseBytes))
+}
@@ -0,0 +1,101 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// Login is the call that starts a cookie session, for the apps that authenticate with a session cookie
+// rather than a header. The cookies it gets are kept in the session's cookie jar.
+type Login struct {
+	URL     string // the login endpoint, relative to the base url when it begins with /
+	Method  string // post by default
+	Body    string // the credentials, as json or a form, the environment variables like ${PASSWORD} are expanded
+	Success string // the status code, or the body field (field=value) that means the login worked, 2xx by default
+}
+
+// UsesLogin returns whether the sessions log in before the tests.
+func (plan *TestPlan) UsesLogin() bool {
+	return len(plan.Login.URL) > 0
+}
+
+// LoginError returns why the login of the shared session failed, nil if it worked or there is none.
+func (plan *TestPlan) LoginError() error {
+	return plan.Session().loginErr
+}
+
+// login calls the login endpoint on the session, and checks that it worked.
+func (plan *TestPlan) login(session *Session) error {
+	l := &plan.Login
+	if !plan.UsesLogin() || plan.dryRun != nil {
+		return nil
+	}
+	loginURL := l.URL
+	if strings.HasPrefix(loginURL, "/") {
+		loginURL = plan.baseURL() + loginURL
+	}
+	method := strings.ToUpper(l.Method)
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+	req := session.Client.R()
+	if body := strings.TrimSpace(os.ExpandEnv(l.Body)); len(body) > 0 {
+		contentType := "application/x-www-form-urlencoded"
+		if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+			contentType = "application/json"
+		}
+		req.SetHeader("Content-Type", contentType).SetBody(body)
+	}
+	if plan.RateLimiter != nil {
+		plan.RateLimiter.Wait()
+	}
+	resp, err := req.Execute(method, loginURL)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("can't log in at %s: %s", loginURL, err.Error()))
+	}
+	if err := l.check(resp); err != nil {
+		return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf("the login at %s failed, %s\n%s",
+			loginURL, err.Error(), string(resp.Body())))
+	}
+	mqutil.Logger.Printf("logged in at %s: %s", loginURL, resp.Status())
+	return nil
+}
+
+// check returns why the response of the login is not a success.
+func (l *Login) check(resp *resty.Response) error {
+	status := resp.StatusCode()
+	if len(l.Success) == 0 {
+		if status < 200 || status >= 300 {
+			return fmt.Errorf("got status %d", status)
+		}
+		return nil
+	}
+	if expected, err := strconv.Atoi(l.Success); err == nil {
+		if status != expected {
+			return fmt.Errorf("got status %d, expecting %d", status, expected)
+		}
+		return nil
+	}
+	field, value := l.Success, ""
+	hasValue := false
+	if i := strings.Index(field, "="); i >= 0 {
+		field, value, hasValue = strings.TrimSpace(field[:i]), strings.TrimSpace(field[i+1:]), true
+	}
+	field = strings.TrimPrefix(field, "$.")
+	body, err := DecodeJSON(resp.Body())
+	if err != nil {
+		return fmt.Errorf("got status %d, the body isn't json", status)
+	}
+	found := getField(body, field)
+	if found == nil || (hasValue && fmt.Sprint(found) != value) {
+		return fmt.Errorf("got status %d, expecting %s in the body", status, l.Success)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MimeHandler decodes a response body of a certain content type into a generic go object,
+// i.e. the same structure we get from json.Unmarshal into an interface{}.
+type MimeHandler func(body []byte) (interface{}, error)
+
+type mimeEntry struct {
+	pattern *regexp.Regexp
+	handler MimeHandler
+}
+
+var mimeHandlers []mimeEntry
+var mimeMutex sync.Mutex
+
+// RegisterMimeHandler registers a handler for the content types matching the pattern. The pattern
+// is a regular expression matched against the media type without parameters (e.g. "application/json").
+// The handlers registered later take priority.
+func RegisterMimeHandler(pattern string, handler MimeHandler) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	mimeMutex.Lock()
+	defer mimeMutex.Unlock()
+	mimeHandlers = append([]mimeEntry{{re, handler}}, mimeHandlers...)
+	return nil
+}
+
+// GetMimeHandler returns the handler for the content type, or nil if none is registered.
+func GetMimeHandler(contentType string) MimeHandler {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	mimeMutex.Lock()
+	defer mimeMutex.Unlock()
+	for _, entry := range mimeHandlers {
+		if entry.pattern.MatchString(mediaType) {
+			return entry.handler
+		}
+	}
+	return nil
+}
+
+// DecodeJSON is the handler for json and vendor +json types. Numbers are kept as json.Number.
+func DecodeJSON(body []byte) (interface{}, error) {
+	var obj interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	err := d.Decode(&obj)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func init() {
+	RegisterMimeHandler(`^application/json$`, DecodeJSON)
+	RegisterMimeHandler(`^application/[^/]+\+json$`, DecodeJSON)
+}
@@ -0,0 +1,244 @@
+package mqplan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/resty.v0"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// The note on the tests of -negative, which send invalid input and expect the server to reject it.
+const NoteNegative = "negative"
+
+// negativeCase breaks one constraint of the operation in the parameters of a test.
+type negativeCase struct {
+	reason string        // what is invalid, e.g. "missing query limit"
+	apply  func(t *Test) // changes the resolved parameters
+}
+
+// paramsOf returns the map of the test's parameters that are sent in the location.
+func (t *Test) paramsOf(in string) map[string]interface{} {
+	switch in {
+	case "path":
+		return t.PathParams
+	case "query":
+		return t.QueryParams
+	case "header":
+		return t.HeaderParams
+	case "formData":
+		return t.FormParams
+	}
+	return nil
+}
+
+// invalidValues returns the values that break the constraints of the schema, by what they break.
+// Strings can't be of the wrong type in a query, so only the body values (inBody) are sent as numbers.
+func invalidValues(schema *mqswag.Schema, inBody bool) map[string]interface{} {
+	values := make(map[string]interface{})
+	isNumber := schema.Type.Contains("integer") || schema.Type.Contains("number")
+	switch {
+	case isNumber:
+		values["wrong type"] = "meqa-not-a-number"
+	case schema.Type.Contains("boolean"):
+		values["wrong type"] = "meqa-not-a-boolean"
+	case schema.Type.Contains("string") && inBody:
+		values["wrong type"] = 12345
+	}
+	if isNumber && schema.Maximum != nil {
+		max := *schema.Maximum + 1
+		if schema.ExclusiveMaximum {
+			max = *schema.Maximum
+		}
+		values[fmt.Sprintf("above maximum %v", *schema.Maximum)] = max
+	}
+	if isNumber && schema.Minimum != nil {
+		min := *schema.Minimum - 1
+		if schema.ExclusiveMinimum {
+			min = *schema.Minimum
+		}
+		values[fmt.Sprintf("below minimum %v", *schema.Minimum)] = min
+	}
+	if schema.Type.Contains("string") && schema.MaxLength != nil {
+		values[fmt.Sprintf("longer than maxLength %d", *schema.MaxLength)] = strings.Repeat("x", int(*schema.MaxLength)+1)
+	}
+	if len(schema.Enum) > 0 {
+		values["not in enum"] = "meqa-invalid-enum"
+	}
+	return values
+}
+
+// resolveSchema follows the $refs of the schema to the definition.
+func resolveSchema(schema *mqswag.Schema, swagger *mqswag.Swagger) *mqswag.Schema {
+	for i := 0; schema != nil && i < 10; i++ {
+		_, referred, err := swagger.GetReferredSchema(schema)
+		if err != nil || referred == nil {
+			break
+		}
+		schema = referred
+	}
+	return schema
+}
+
+// negativeCases returns the ways to break the constraints of the operation the test calls: the required
+// parameters and body fields missing, and the values out of their range, of the wrong type, too long or
+// not in their enum. They are sorted by reason.
+func (plan *TestPlan) negativeCases(t *Test) []*negativeCase {
+	pathItem, ok := plan.swagger.Paths.Paths[t.Path]
+	if !ok {
+		return nil
+	}
+	op := GetOperationByMethod(&pathItem, strings.ToLower(t.Method))
+	if op == nil {
+		return nil
+	}
+	var cases []*negativeCase
+	for _, param := range ParamsAdd(append([]spec.Parameter(nil), op.Parameters...), pathItem.Parameters) {
+		in, name := param.In, param.Name
+		if in == "body" {
+			cases = append(cases, plan.bodyCases(&param)...)
+			continue
+		}
+		// Without a path parameter the request goes to another route.
+		if param.Required && in != "path" {
+			cases = append(cases, &negativeCase{
+				reason: fmt.Sprintf("missing %s %s", in, name),
+				apply: func(t *Test) {
+					delete(t.paramsOf(in), name)
+				},
+			})
+		}
+		schema := mqswag.CreateSchemaFromSimple(&param.SimpleSchema, &param.CommonValidations)
+		for reason, value := range invalidValues(schema, false) {
+			value := value
+			cases = append(cases, &negativeCase{
+				reason: fmt.Sprintf("%s %s %s", in, name, reason),
+				apply: func(t *Test) {
+					if params := t.paramsOf(in); params != nil {
+						params[name] = value
+					}
+				},
+			})
+		}
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].reason < cases[j].reason })
+	return cases
+}
+
+// bodyCases returns the ways to break the body of the operation, in its top level fields.
+func (plan *TestPlan) bodyCases(param *spec.Parameter) []*negativeCase {
+	var cases []*negativeCase
+	if param.Required {
+		cases = append(cases, &negativeCase{
+			reason: "missing body",
+			apply:  func(t *Test) { t.BodyParams = nil },
+		})
+	}
+	schema := resolveSchema((*mqswag.Schema)(param.Schema), plan.swagger)
+	if schema == nil {
+		return cases
+	}
+	setField := func(field string, value interface{}, remove bool) func(t *Test) {
+		return func(t *Test) {
+			body, ok := t.BodyParams.(map[string]interface{})
+			if !ok {
+				return
+			}
+			if remove {
+				delete(body, field)
+			} else {
+				body[field] = value
+			}
+		}
+	}
+	for _, field := range schema.Required {
+		cases = append(cases, &negativeCase{
+			reason: fmt.Sprintf("missing body field %s", field),
+			apply:  setField(field, nil, true),
+		})
+	}
+	for field, property := range schema.Properties {
+		property := property
+		for reason, value := range invalidValues((*mqswag.Schema)(&property), true) {
+			cases = append(cases, &negativeCase{
+				reason: fmt.Sprintf("body field %s %s", field, reason),
+				apply:  setField(field, value, false),
+			})
+		}
+	}
+	return cases
+}
+
+// firstNegative returns whether the test is the first of the run to call its operation, the one the
+// negative cases run after.
+func (plan *TestPlan) firstNegative(t *Test) bool {
+	key := strings.ToLower(t.Method) + " " + t.Path
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if plan.negativeOps == nil {
+		plan.negativeOps = make(map[string]bool)
+	}
+	if plan.negativeOps[key] {
+		return false
+	}
+	plan.negativeOps[key] = true
+	return true
+}
+
+// runNegatives runs the negative cases of the test's operation, each as a test of its own. They don't
+// add objects to the DB, and their failures don't stop the suite.
+func (plan *TestPlan) runNegatives(tc *TestSuite, test *Test, parentTest *Test) {
+	if test.Anonymous || !plan.firstNegative(test) {
+		return
+	}
+	name := test.Name
+	if parentTest != nil {
+		name = parentTest.Name
+	}
+	for _, c := range plan.negativeCases(test) {
+		if len(plan.stopped()) > 0 {
+			return
+		}
+		dup := test.Duplicate()
+		dup.Strict = tc.Strict
+		if parentTest != nil {
+			dup.CopyParent(parentTest)
+		}
+		dup.ResolveHistoryParameters(&History)
+		dup.Name = fmt.Sprintf("%s (negative: %s)", name, c.reason)
+		dup.negative = c
+		dup.Notes = []string{NoteNegative}
+		dup.err = dup.Run(tc)
+		plan.addResult(tc, dup)
+		if dup.err != nil {
+			plan.failFast(dup)
+		}
+	}
+}
+
+// checkRejected checks the response to a negative case, which should be a 4xx. A 5xx means the server
+// doesn't validate the input, a 2xx or 3xx that it took the invalid input.
+func (t *Test) checkRejected(resp *resty.Response) error {
+	if t.err != nil {
+		fmt.Printf("REST call hit the following error: %s\n", t.err.Error())
+		return t.err
+	}
+	t.resp = resp
+	status := resp.StatusCode()
+	t.Expect = map[string]interface{}{ExpectStatus: status}
+	if status >= 400 && status < 500 {
+		fmt.Printf("... expecting a 4xx for %s got status: %d. Success\n", t.negative.reason, status)
+		return nil
+	}
+	fmt.Printf("... expecting a 4xx for %s got status: %d. Fail\n", t.negative.reason, status)
+	if status >= 500 {
+		return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+			"=== test failed, %s got response code %d, the input isn't validated ===", t.negative.reason, status))
+	}
+	return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+		"=== test failed, %s was accepted with response code %d ===", t.negative.reason, status))
+}
@@ -0,0 +1,93 @@
+package mqplan
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// isIdField checks whether the field name looks like one that holds an id, e.g. id, petId, order_id.
+func isIdField(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "id" || lower == "uuid" || strings.HasSuffix(lower, "_id") || strings.HasSuffix(name, "Id") ||
+		strings.HasSuffix(name, "ID")
+}
+
+// IdNormalizer replaces the generated ids with stable placeholders, so that the results of different
+// runs can be compared. The same id value always gets the same placeholder.
+type IdNormalizer struct {
+	placeholders map[string]string
+}
+
+func NewIdNormalizer() *IdNormalizer {
+	return &IdNormalizer{make(map[string]string)}
+}
+
+func (n *IdNormalizer) placeholder(value interface{}) string {
+	key := fmt.Sprint(value)
+	p, ok := n.placeholders[key]
+	if !ok {
+		p = fmt.Sprintf("<id%d>", len(n.placeholders)+1)
+		n.placeholders[key] = p
+	}
+	return p
+}
+
+// normalize returns a copy of the object with the ids replaced. isId tells whether the object is the
+// value of an id field.
+func (n *IdNormalizer) normalize(obj interface{}, isId bool) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		return n.normalizeMap(o)
+	case []interface{}:
+		a := make([]interface{}, len(o))
+		for i, v := range o {
+			a[i] = n.normalize(v, isId)
+		}
+		return a
+	case string:
+		if isId || uuidRegex.MatchString(o) {
+			return n.placeholder(o)
+		}
+		return o
+	case nil, bool:
+		return o
+	}
+	if isId {
+		// numbers
+		return n.placeholder(obj)
+	}
+	return obj
+}
+
+func (n *IdNormalizer) normalizeMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	// By sorted key, so that the ids get the same placeholders in every run.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make(map[string]interface{})
+	for _, k := range keys {
+		result[k] = n.normalize(m[k], isIdField(k))
+	}
+	return result
+}
+
+// NormalizeTest returns a copy of the test with the ids in the parameters and expect values replaced.
+func (n *IdNormalizer) NormalizeTest(t *Test) *Test {
+	test := *t
+	test.QueryParams = n.normalizeMap(t.QueryParams)
+	test.FormParams = n.normalizeMap(t.FormParams)
+	test.PathParams = n.normalizeMap(t.PathParams)
+	test.HeaderParams = n.normalizeMap(t.HeaderParams)
+	test.BodyParams = n.normalize(t.BodyParams, false)
+	test.Expect = n.normalizeMap(t.Expect)
+	return &test
+}
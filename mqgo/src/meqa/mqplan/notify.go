@@ -0,0 +1,121 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// When the run posts its summary to the -webhook and -slack urls.
+const (
+	NotifyAlways  = "always"
+	NotifyFailure = "failure" // only when a test failed, or the run failed otherwise
+)
+
+// CheckNotifyOn returns an error if the -notify-on value isn't one we support.
+func CheckNotifyOn(notifyOn string) error {
+	switch notifyOn {
+	case "", NotifyAlways, NotifyFailure:
+		return nil
+	}
+	return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown -notify-on %s, expecting always or failure", notifyOn))
+}
+
+// How long the call to a webhook may take.
+const notifyTimeout = 30 * time.Second
+
+// The most failed tests listed in the slack message.
+const slackMaxFailed = 20
+
+// RunFailed returns whether the run failed, a test failed or the exit code says so.
+func (summary *RunSummary) RunFailed() bool {
+	return summary.Failed > 0 || summary.ExitCode != 0
+}
+
+// Notify posts the summary to the plan's Webhook as json, and to its SlackWebhook as a slack message.
+// With NotifyOn failure, it only posts the summaries of failed runs. The calls that fail are logged, they
+// don't change the outcome of the run.
+func (plan *TestPlan) Notify(summary *RunSummary) {
+	if len(plan.Webhook) == 0 && len(plan.SlackWebhook) == 0 {
+		return
+	}
+	if plan.NotifyOn == NotifyFailure && !summary.RunFailed() {
+		mqutil.Logger.Printf("the run passed, not notifying with -notify-on %s", plan.NotifyOn)
+		return
+	}
+	// The webhooks are outside of the tested servers, they're called with the default TLS config.
+	client := resty.New().SetTransport(&http.Transport{Proxy: plan.proxy()}).SetTimeout(notifyTimeout)
+	if len(plan.Webhook) > 0 {
+		notify(client, plan.Webhook, summary)
+	}
+	if len(plan.SlackWebhook) > 0 {
+		notify(client, plan.SlackWebhook, slackMessage(summary))
+	}
+}
+
+func notify(client *resty.Client, webhookURL string, payload interface{}) {
+	resp, err := client.R().SetHeader("Content-Type", "application/json").SetBody(payload).Post(webhookURL)
+	if err == nil && resp.StatusCode() >= 300 {
+		err = fmt.Errorf("got %s %s", resp.Status(), string(resp.Body()))
+	}
+	if err != nil {
+		mqutil.Warnf("can't notify %s: %s", webhookHost(webhookURL), err.Error())
+		return
+	}
+	mqutil.Logger.Printf("notified %s: %s", webhookHost(webhookURL), resp.Status())
+}
+
+// webhookHost returns the url without its path, which is often the secret of the webhook.
+func webhookHost(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "the webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// slackMessage returns the summary as a slack message, green when the run passed and red when it failed.
+func slackMessage(summary *RunSummary) map[string]interface{} {
+	status, color := "passed", "#2eb886"
+	if summary.RunFailed() {
+		status, color = "failed", "#d50200"
+	}
+	title := fmt.Sprintf("meqa run %s", status)
+	if len(summary.BaseURL) > 0 {
+		title += " against " + summary.BaseURL
+	}
+	field := func(name string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%v", name, value)}
+	}
+	blocks := []interface{}{
+		map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "*" + title + "*"}},
+		map[string]interface{}{"type": "section", "fields": []interface{}{
+			field("Passed", summary.Passed),
+			field("Failed", summary.Failed),
+			field("Skipped", summary.Skipped),
+			field("Duration", fmt.Sprintf("%.1fs", summary.Duration)),
+		}},
+	}
+	if len(summary.FailedTests) > 0 {
+		var lines []string
+		for i, name := range summary.FailedTests {
+			if i == slackMaxFailed {
+				lines = append(lines, fmt.Sprintf("and %d more", len(summary.FailedTests)-slackMaxFailed))
+				break
+			}
+			lines = append(lines, "• "+name)
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*Failed tests*\n" + strings.Join(lines, "\n")}})
+	}
+	return map[string]interface{}{
+		"text":        fmt.Sprintf("%s: %d passed, %d failed, %d skipped", title, summary.Passed, summary.Failed, summary.Skipped),
+		"attachments": []interface{}{map[string]interface{}{"color": color, "blocks": blocks}},
+	}
+}
@@ -0,0 +1,99 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// How long before its expiry a token is considered expired, so that it doesn't expire in flight.
+const tokenExpiryMargin = 30 * time.Second
+
+// OAuth2 is the client of the OAuth2 client credentials grant. The token is cached until it expires.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	token  string
+	expiry time.Time
+	mutex  sync.Mutex
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// UsesOAuth2 returns whether the requests are authenticated with OAuth2 client credentials.
+func (plan *TestPlan) UsesOAuth2() bool {
+	return len(plan.OAuth2.TokenURL) > 0
+}
+
+// OAuth2Token returns the access token, running the client credentials grant when there is no cached
+// token, or it has expired, or refresh is set.
+func (plan *TestPlan) OAuth2Token(refresh bool) (string, error) {
+	if plan.dryRun != nil {
+		return dryRunToken, nil
+	}
+	o := &plan.OAuth2
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if !refresh && len(o.token) > 0 && (o.expiry.IsZero() || time.Now().Before(o.expiry)) {
+		return o.token, nil
+	}
+
+	form := map[string]string{"grant_type": "client_credentials"}
+	if len(o.Scopes) > 0 {
+		form["scope"] = strings.Join(o.Scopes, " ")
+	}
+	resp, err := plan.NewSession().Client.R().
+		SetBasicAuth(o.ClientID, o.ClientSecret).
+		SetFormData(form).
+		SetHeader("Accept", "application/json").
+		Post(o.TokenURL)
+	if err != nil {
+		return "", mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("can't get an OAuth2 token from %s: %s", o.TokenURL, err.Error()))
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf("can't get an OAuth2 token from %s: %s\n%s",
+			o.TokenURL, resp.Status(), string(resp.Body())))
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(resp.Body(), &token); err != nil || len(token.AccessToken) == 0 {
+		return "", mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf("no access token in the response of %s:\n%s",
+			o.TokenURL, string(resp.Body())))
+	}
+	o.token = token.AccessToken
+	o.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin)
+	}
+	mqutil.Logger.Printf("got an OAuth2 token from %s, expires in %d seconds", o.TokenURL, token.ExpiresIn)
+	return o.token, nil
+}
+
+// retryWithNewToken gets a new OAuth2 token for the request when the call was rejected with a 401, as the
+// token may have been revoked or expired mid-run. Returns whether the call should be retried.
+func (t *Test) retryWithNewToken(tc *TestSuite, req *resty.Request, resp *resty.Response, err error) bool {
+	if err != nil || resp == nil || resp.StatusCode() != http.StatusUnauthorized {
+		return false
+	}
+	token, err := tc.plan.OAuth2Token(true)
+	if err != nil {
+		mqutil.Logger.Println(err.Error())
+		return false
+	}
+	fmt.Printf("... got 401, retrying with a new OAuth2 token\n")
+	req.SetAuthToken(token)
+	return true
+}
@@ -0,0 +1,235 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/resty.v0"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// The query parameters of list operations that page through the collection, by pagination style.
+var (
+	cursorParams = []string{"cursor", "pageToken", "page_token", "continuationToken", "continuation_token", "after"}
+	offsetParams = []string{"offset", "skip", "start"}
+	pageParams   = []string{"page", "pageNumber", "page_number"}
+	limitParams  = []string{"limit", "pageSize", "page_size", "per_page", "perPage", "size", "count"}
+)
+
+// The response fields with the cursor or the url of the next page, e.g. {"items": [...], "next": "..."}.
+var nextFields = []string{"next", "nextLink", "next_link", "nextPage", "next_page", "nextPageToken", "next_page_token",
+	"nextCursor", "next_cursor", "links.next", "_links.next.href", "paging.next", "pagination.next",
+	"meta.next_cursor", "meta.nextCursor"}
+
+// The pagination styles.
+const (
+	pageCursor = "cursor" // the next page is asked for with the cursor the last one returned
+	pageOffset = "offset" // with the offset of its first item
+	pageNumber = "page"   // with its number
+)
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>\s*;[^,]*rel="?next"?`)
+
+// pager is how a list operation is paged through, found from its query parameters.
+type pager struct {
+	style string
+	param string // the query parameter of the cursor, offset or page number
+	limit string // the query parameter of the page size, if any
+}
+
+// queryParam returns the name of the operation's query parameter that is one of the names.
+func queryParam(params []spec.Parameter, names []string) string {
+	for _, name := range names {
+		for _, param := range params {
+			if param.In == "query" && strings.EqualFold(param.Name, name) {
+				return param.Name
+			}
+		}
+	}
+	return ""
+}
+
+// pager returns how the operation of the test is paged through. With no cursor, offset or page parameter,
+// the operation can still be paged through with the next links of its responses.
+func (t *Test) pager() *pager {
+	p := &pager{limit: queryParam(t.op.Parameters, limitParams)}
+	if p.param = queryParam(t.op.Parameters, cursorParams); len(p.param) > 0 {
+		p.style = pageCursor
+	} else if p.param = queryParam(t.op.Parameters, offsetParams); len(p.param) > 0 {
+		p.style = pageOffset
+	} else if p.param = queryParam(t.op.Parameters, pageParams); len(p.param) > 0 {
+		p.style = pageNumber
+	}
+	return p
+}
+
+// nextPage returns the cursor or the url of the next page, from the response's Link header or body.
+func nextPage(resp *resty.Response, body interface{}) string {
+	if match := linkNextRegex.FindStringSubmatch(resp.Header().Get("Link")); match != nil {
+		return match[1]
+	}
+	for _, field := range nextFields {
+		if next, ok := getField(body, field).(string); ok && len(next) > 0 {
+			return next
+		}
+	}
+	return ""
+}
+
+// isLink returns whether the next page is given as a url rather than a cursor.
+func isLink(next string) bool {
+	return strings.HasPrefix(next, "http://") || strings.HasPrefix(next, "https://") || strings.HasPrefix(next, "/")
+}
+
+// pageItems returns the number of items on the page, the length of the array or of the longest array field.
+func pageItems(body interface{}) int {
+	if array, ok := body.([]interface{}); ok {
+		return len(array)
+	}
+	items := 0
+	if object, ok := body.(map[string]interface{}); ok {
+		for _, v := range object {
+			if array, ok := v.([]interface{}); ok && len(array) > items {
+				items = len(array)
+			}
+		}
+	}
+	return items
+}
+
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// followPages reads the pages of the collection after the first one, up to the plan's MaxPages in all,
+// and adds the objects on them to the DB like the ones on the first page. The pages aren't checked, a
+// page that can't be read ends the paging. The path is the url of the first page.
+func (t *Test) followPages(tc *TestSuite, baseURL string, path string, resp *resty.Response, body interface{}) {
+	p := t.pager()
+	query := mqutil.MapCopy(t.QueryParams)
+	if query == nil {
+		query = make(map[string]interface{})
+	}
+	limit, hasLimit := intValue(query[p.limit])
+	position, _ := intValue(query[p.param])
+	if p.style == pageNumber && position == 0 {
+		position = 1
+	}
+	for page := 2; page <= tc.plan.MaxPages && !tc.plan.Stopped(); page++ {
+		items := pageItems(body)
+		next := nextPage(resp, body)
+		link := ""
+		switch {
+		case len(next) > 0 && isLink(next):
+			link = next
+		case p.style == pageCursor && len(next) > 0:
+			query[p.param] = next
+		case p.style == pageOffset || p.style == pageNumber:
+			if items == 0 || (hasLimit && items < limit) {
+				return
+			}
+			if p.style == pageOffset {
+				position += items
+			} else {
+				position++
+			}
+			query[p.param] = position
+		default:
+			return
+		}
+
+		var err error
+		resp, err = t.fetchPage(tc, baseURL, path, query, link)
+		if err != nil {
+			mqutil.Warnf("can't read page %d of %s %s: %s", page, t.Method, t.Path, err.Error())
+			return
+		}
+		if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+			mqutil.Warnf("can't read page %d of %s %s, got %s", page, t.Method, t.Path, resp.Status())
+			return
+		}
+		body, err = DecodeJSON(resp.Body())
+		if err != nil {
+			mqutil.Warnf("can't decode page %d of %s %s: %s", page, t.Method, t.Path, err.Error())
+			return
+		}
+		if pageItems(body) == 0 {
+			return
+		}
+		t.Pages = page
+		fmt.Printf("... read page %d, %d items\n", page, pageItems(body))
+		t.addPageObjects(resp.StatusCode(), body)
+	}
+}
+
+// fetchPage gets the next page, with the query, or at the link in the last page, relative to its path.
+func (t *Test) fetchPage(tc *TestSuite, baseURL string, path string, query map[string]interface{}, link string) (*resty.Response, error) {
+	req := tc.R()
+	if _, err := t.setCredentials(tc, req); err != nil {
+		return nil, err
+	}
+	t.SetHeaders(tc.plan, req)
+	if len(link) > 0 {
+		base, err := url.Parse(path)
+		ref, refErr := url.Parse(link)
+		if err == nil && refErr == nil {
+			link = base.ResolveReference(ref).String()
+		}
+		path = link
+	} else {
+		pageTest := t.Duplicate()
+		pageTest.op = t.op
+		pageTest.QueryParams = query
+		path = baseURL + pageTest.SetRequestParameters(req)
+	}
+	if tc.plan.SigV4 && !t.Anonymous {
+		if err := t.SignSigV4(tc.plan, req, path, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if tc.plan.RateLimiter != nil {
+		tc.plan.RateLimiter.Wait()
+	}
+	return req.Get(path)
+}
+
+// addPageObjects adds the objects on a page to the DB, as found by the response schema of the status.
+func (t *Test) addPageObjects(status int, body interface{}) {
+	if t.Strict || t.op.Responses == nil {
+		return
+	}
+	respSpec := t.op.Responses.Default
+	if respObject, ok := t.op.Responses.StatusCodeResponses[status]; ok {
+		respSpec = &respObject
+	}
+	if respSpec == nil || respSpec.Schema == nil {
+		return
+	}
+	collection := make(map[string][]interface{})
+	if err := (*mqswag.Schema)(respSpec.Schema).Parses("", body, collection, true, t.db.Swagger); err != nil {
+		mqutil.Logger.Printf("a page of %s %s doesn't match the response schema: %s", t.Method, t.Path, err.Error())
+	}
+	for className, classList := range collection {
+		for _, entry := range classList {
+			t.db.Insert(className, entry, nil, t.PathParams)
+		}
+	}
+}
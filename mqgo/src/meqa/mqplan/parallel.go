@@ -0,0 +1,50 @@
+package mqplan
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"meqa/mqutil"
+)
+
+// RunParallel runs the named test suites on up to workers goroutines. The tests within a suite still run
+// one after another, as the later ones use the objects created by the earlier ones. The results are put
+// back in the order of names, however the suites finish. Returns the errors of the suites, indexed like names.
+func (plan *TestPlan) RunParallel(names []string, workers int) []error {
+	if workers < 1 {
+		workers = 1
+	}
+	start := len(plan.resultList)
+	errs := make([]error, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mqutil.Logger.Printf("\n---\nTest suite: %s\n", names[i])
+				fmt.Printf("\n---\nTest suite: %s\n", names[i])
+				errs[i] = plan.Run(names[i], nil)
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	order := make(map[*TestSuite]int)
+	for i, name := range names {
+		if tc, ok := plan.SuiteMap[name]; ok {
+			order[tc] = i
+		}
+	}
+	results := plan.resultList[start:]
+	sort.SliceStable(results, func(i, j int) bool {
+		return order[results[i].root] < order[results[j].root]
+	})
+	return errs
+}
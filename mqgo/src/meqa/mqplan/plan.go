@@ -1,11 +1,17 @@
 package mqplan
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,7 +24,8 @@ import (
 )
 
 const (
-	MeqaInit = "meqa_init"
+	MeqaInit      = "meqa_init"
+	PlanVariables = "variables"
 )
 
 type TestParams struct {
@@ -79,8 +86,11 @@ type TestSuite struct {
 	Password string
 	ApiToken string
 
-	plan *TestPlan
-	db   *mqswag.DB // objects generated/obtained as part of this suite
+	plan    *TestPlan
+	db      *mqswag.DB // objects generated/obtained as part of this suite
+	session *Session
+	root    *TestSuite // the top level suite this one runs as part of
+	mutex   sync.Mutex // a suite runs once at a time, even when referenced by suites running in parallel
 
 	comment string
 }
@@ -107,6 +117,9 @@ type TestPlan struct {
 	db        *mqswag.DB
 	swagger   *mqswag.Swagger
 
+	// BaseURL overrides the scheme, host and basePath from the swagger spec when set.
+	BaseURL string
+
 	// global parameters
 	TestParams `yaml:",inline,omitempty" json:",inline,omitempty"`
 	Strict     bool
@@ -116,6 +129,202 @@ type TestPlan struct {
 	Password string
 	ApiToken string
 
+	// Sign the requests with AWS signature version 4 instead of the above.
+	SigV4          bool
+	AWSRegion      string
+	AWSService     string
+	AWSCredentials AWSCredentials
+
+	// Authenticate with a bearer token from the OAuth2 client credentials grant instead, when its TokenURL is set.
+	OAuth2 OAuth2
+
+	// Or with the session cookie the Login gets, when its URL is set.
+	Login Login
+
+	// The keys of the swagger's apiKey security schemes, sent along with the credentials above.
+	APIKeys APIKeys
+
+	// The custom headers sent with every request, e.g. tenant identifiers or feature flags.
+	Headers Headers
+
+	// The variables set on the command line, they override the ones in the plan's variables section.
+	Variables map[string]string
+
+	// The file parameters are uploaded from the file named after them in FixturesDir, when there is one.
+	FixturesDir string
+
+	// Data generation. Fanout is the number of array elements generated at each nesting
+	// level (0 means random), MaxDepth bounds how deep nested arrays/objects go (0 means no limit).
+	Fanout   int
+	MaxDepth int
+
+	// The GETs of collections read up to MaxPages pages, following the offset, page number or cursor
+	// parameters of the operation or the next links of its responses, so that the tests can use the objects
+	// on all of them. 0 or 1 means only the first page.
+	MaxPages int
+
+	// With ArrayVariety, arrays of enums hold distinct values, and the entries of arrays of objects
+	// differ in their key field, instead of being random picks that often repeat.
+	ArrayVariety bool
+
+	// When CORSOrigin is set, every test is followed by a CORS preflight check. CORSMethods are the
+	// methods we expect in Access-Control-Allow-Methods on top of the test's own method.
+	CORSOrigin  string
+	CORSMethods []string
+
+	// Plausible string values are generated for fields like email and city in this locale. An empty
+	// locale turns the faker off. FakerRules override the default field name to category mapping.
+	Locale     string
+	FakerRules []*FakerRule
+
+	// Fail the tests whose responses don't match the response schema. Operations without a response
+	// schema are skipped with a no-schema note, unless StrictValidation is set.
+	ValidateResponse bool
+	StrictValidation bool
+
+	// When an operation returns 202, we poll the status url found in the AsyncLocation header (or in the
+	// body field, when it starts with "$.") until the operation completes or AsyncTimeout expires.
+	// AsyncStatusField is the body field of the status response that tells whether it's still running.
+	AsyncPollInterval time.Duration
+	AsyncTimeout      time.Duration
+	AsyncLocation     string
+	AsyncStatusField  string
+
+	// Send the query parameters sorted by name, for the gateways that sign the canonical query string.
+	SortQuery bool
+
+	// The media type the request bodies are sent as, when the operation accepts it. With AllContentTypes,
+	// the tests of operations that accept several media types run once per type.
+	ContentType     string
+	AllContentTypes bool
+
+	// Run the tests of optionally authenticated operations a second time, without credentials.
+	AnonymousAccess bool
+
+	// With Negative, the first test of each operation is followed by requests that break the swagger
+	// constraints of its parameters, which should get a 4xx. See negativeCases.
+	Negative    bool
+	negativeOps map[string]bool // the operations whose negative cases ran
+
+	// With EndpointHealthCheck, the route of each operation is checked before its tests run, and the
+	// tests of unreachable routes are skipped rather than failed.
+	EndpointHealthCheck bool
+	SkippedUnreachable  int
+	reachableRoutes     map[string]bool
+
+	// Only run the tests that call safe (read-only) operations.
+	ReadOnly bool
+
+	// The tests of deprecated operations are skipped unless IncludeDeprecated is set.
+	IncludeDeprecated bool
+	SkippedDeprecated int
+
+	// Only the suites that call an operation with one of the SuiteTags, and whose name matches SuiteMatch,
+	// are run. See SelectSuites.
+	SuiteTags  []string
+	SuiteMatch *regexp.Regexp
+
+	// With -rerun, only the suites that failed in the earlier result file run, see LoadRerun.
+	Rerun map[string]bool
+
+	// With VerifyState the objects of the state file are checked with a GET before the run, see CheckState.
+	VerifyState bool
+
+	// With PreferExisting the tests that create an object of a definition the state file has objects of are
+	// skipped, the later tests use the loaded objects instead. Counted in SkippedExisting.
+	PreferExisting  bool
+	SkippedExisting int
+
+	// With DryRun the requests are printed and sent to a stub server instead, see NewDryRunServer.
+	DryRun bool
+
+	// The run stops when it's past the deadline, the tests not started yet are skipped. Zero means no deadline.
+	// The retries that weren't started because they couldn't finish before the deadline are counted in
+	// SkippedRetries.
+	Deadline        time.Time
+	SkippedDeadline int
+	SkippedRetries  int
+
+	// With FailFast the run stops at the first failed test, FirstFailure. The tests left are skipped.
+	FailFast        bool
+	FirstFailure    string
+	SkippedFailFast int
+	stopNote        string // why the run stopped, the note of the tests it skips
+
+	// The run can be stopped with Interrupt, e.g. on Ctrl-C. The tests left are skipped.
+	SkippedInterrupted int
+
+	// When set, the resolved request of every test is appended to this file as a line of json.
+	DumpRequests string
+	dumpFile     *os.File
+
+	// When set, the run is exported to this file as a Postman collection.
+	PostmanFile string
+
+	// When set, the coverage of the swagger operations by the run is written to this file.
+	CoverageFile string
+
+	// The number of times the test suites are run. P95Under, when set, is the limit of the p95 latency
+	// of each operation across the repeats. The latency percentiles go into the result file.
+	Repeat   int
+	P95Under time.Duration
+
+	// Fail the tests whose call takes longer than MaxLatency, unless their operation has a budget of its
+	// own in x-meqa-max-latency. Zero means no limit.
+	MaxLatency time.Duration
+
+	// How the tests are grouped into suites in the result file, see GroupBySuite etc.
+	GroupBy string
+
+	// The format of the result file, yaml by default, ResultFormatJUnit or ResultFormatHTML.
+	ResultFormat string
+
+	// Replace the generated ids in the result file with stable placeholders.
+	NormalizeIds bool
+
+	// The number of test suites run at the same time by RunParallel.
+	Parallel int
+
+	// The number of tests of a suite run at the same time, see concurrent.
+	SuiteParallel int
+
+	// Where Notify posts the summary of the run, and when, NotifyAlways or NotifyFailure.
+	Webhook      string
+	SlackWebhook string
+	NotifyOn     string
+
+	// The seed of the random generated values, see SeedRandom. It's written to the result file.
+	RandSeed int64
+
+	// Guards the results, counters and caches shared by the suites running in parallel.
+	mutex sync.Mutex
+
+	// Called around the call of each test, see BeforeRequest and AfterResponse.
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
+
+	// The locks of the operations that must be called serially.
+	operationLocks map[string]*sync.Mutex
+	operationMutex sync.Mutex
+
+	// The directory of the test plan file, the data files of data-driven tests are relative to it.
+	dataDir string
+
+	// The HTTP session shared by the suites of the run. With FreshSessionPerSuite, each top level suite
+	// gets a session of its own, so cookies don't leak from one suite to the next.
+	FreshSessionPerSuite bool
+	MaxResponseBytes     int64               // stop reading the responses past this size, 0 means no limit
+	Timeout              time.Duration       // how long to wait for each response, 0 means no limit
+	Redirects            string              // the -redirects policy, see ParseRedirects
+	NoCompression        bool                // don't ask for gzip or deflate responses, see decompressor
+	Retries              int                 // how many times a call is retried after a transient failure
+	RetryWait            time.Duration       // the wait before the first retry, doubled with every retry
+	RateLimiter          *mqutil.RateLimiter // shared by all the calls of the run, nil means no limit
+	TLSConfig            *tls.Config
+	Proxy                func(*http.Request) (*url.URL, error) // the proxy of the sessions' transports, see mqutil.ProxyFunc
+	session              *Session
+	dryRun               *SelfTestServer // where the requests go instead in a dry run
+
 	// Run result.
 	resultList []*Test
 
@@ -167,6 +376,7 @@ func (plan *TestPlan) AddFromString(data string) error {
 
 func (plan *TestPlan) InitFromFile(path string, db *mqswag.DB) error {
 	plan.Init(db.Swagger, db)
+	plan.dataDir = filepath.Dir(path)
 
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -175,12 +385,94 @@ func (plan *TestPlan) InitFromFile(path string, db *mqswag.DB) error {
 		return err
 	}
 	chunks := strings.Split(string(data), "---")
+	var suiteChunks []string
+	defaults := make(map[string]string)
 	for _, chunk := range chunks {
-		plan.AddFromString(chunk)
+		if vars, ok := parseVariables(chunk); ok {
+			for k, v := range vars {
+				defaults[k] = v
+			}
+			continue
+		}
+		suiteChunks = append(suiteChunks, chunk)
+	}
+	vars := plan.resolveVariables(defaults)
+	missing := make(map[string]bool)
+	var substituted []string
+	for _, chunk := range suiteChunks {
+		substituted = append(substituted, substituteVariables(chunk, vars, missing))
+	}
+	if len(missing) > 0 {
+		// Running with the literal ${name} would only fail later, in a more confusing way.
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"the test plan %s uses variables that aren't set: %s. Set them with -var name=value, in the environment, "+
+				"or in the plan's variables section", path, strings.Join(names, ", ")))
+	}
+	for _, chunk := range substituted {
+		if err = plan.AddFromString(chunk); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// parseVariables parses the "variables" section of the test plan file. The section should be a
+// document of its own.
+func parseVariables(chunk string) (map[string]string, bool) {
+	var section map[string]map[string]interface{}
+	err := yaml.Unmarshal([]byte(chunk), &section)
+	if err != nil || len(section) != 1 || section[PlanVariables] == nil {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for k, v := range section[PlanVariables] {
+		vars[k] = fmt.Sprint(v)
+	}
+	return vars, true
+}
+
+// resolveVariables merges the plan defaults with the environment and the command line, in the order
+// of increasing priority.
+func (plan *TestPlan) resolveVariables(defaults map[string]string) map[string]string {
+	vars := make(map[string]string)
+	for k, v := range defaults {
+		vars[k] = v
+		if env, ok := os.LookupEnv(k); ok {
+			vars[k] = env
+		}
+	}
+	for k, v := range plan.Variables {
+		vars[k] = v
+	}
+	return vars
+}
+
+var variableRegex = regexp.MustCompile(`\$?\$\{(\w+)\}`)
+
+// substituteVariables replaces ${name} in the text with the variable's value, and $${name} with a literal
+// ${name}. The names of the variables that aren't set are added to missing.
+func substituteVariables(text string, vars map[string]string, missing map[string]bool) string {
+	return variableRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[2 : len(ref)-1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		missing[name] = true
+		return ref
+	})
+}
+
 func WriteComment(comment string, f *os.File) {
 	ar := strings.Split(comment, "\n")
 	for _, line := range ar {
@@ -221,18 +513,200 @@ func (plan *TestPlan) DumpToFile(path string) error {
 }
 
 func (plan *TestPlan) WriteResultToFile(path string) error {
-	// We create a new test plan that just contain all the tests in one test suite.
-	p := &TestPlan{}
-	tc := &TestSuite{}
+	if plan.ResultFormat == ResultFormatJUnit {
+		// CI servers read junit by suite, so that's the default grouping there.
+		groupBy := plan.GroupBy
+		if len(groupBy) == 0 {
+			groupBy = GroupBySuite
+		}
+		return plan.resultPlan(groupBy).WriteJUnitToFile(path)
+	}
+	if plan.ResultFormat == ResultFormatHTML {
+		groupBy := plan.GroupBy
+		if len(groupBy) == 0 {
+			groupBy = GroupBySuite
+		}
+		return plan.resultPlan(groupBy).WriteHTMLToFile(path, plan.baseURL())
+	}
+	return plan.resultPlan(plan.GroupBy).DumpToFile(path)
+}
+
+// resultPlan returns the test plan written to the result file, with the tests of the last run grouped by groupBy.
+func (plan *TestPlan) resultPlan(groupBy string) *TestPlan {
+	// We create a new test plan that just contain all the tests in one test suite, or in one suite per
+	// group when the plan's GroupBy is set.
+	p := &TestPlan{RandSeed: plan.RandSeed}
+	p.SuiteMap = make(map[string]*TestSuite)
 	// Test case name is the current time.
-	tc.Name = time.Now().Format(time.RFC3339)
-	p.SuiteMap = map[string]*TestSuite{tc.Name: tc}
-	p.SuiteList = append(p.SuiteList, tc)
+	runName := time.Now().UTC().Format(time.RFC3339)
 
+	normalizer := NewIdNormalizer()
 	for _, test := range plan.resultList {
+		name := runName
+		if group := plan.resultGroup(test, groupBy); len(group) > 0 {
+			name = group
+		}
+		tc, ok := p.SuiteMap[name]
+		if !ok {
+			tc = &TestSuite{Name: name}
+			p.SuiteMap[name] = tc
+			p.SuiteList = append(p.SuiteList, tc)
+		}
+		if test.root != nil {
+			test.Suite = test.root.Name
+		}
+		if test.err != nil {
+			test.Error = test.err.Error()
+		}
+		if plan.NormalizeIds {
+			test = normalizer.NormalizeTest(test)
+		}
 		tc.Tests = append(tc.Tests, test)
 	}
-	return p.DumpToFile(path)
+	if len(p.SuiteList) == 0 {
+		tc := &TestSuite{Name: runName}
+		p.SuiteMap[runName] = tc
+		p.SuiteList = append(p.SuiteList, tc)
+	}
+	var comments []string
+	if plan.RandSeed != 0 {
+		comments = append(comments, fmt.Sprintf("Random seed %d, rerun with -randseed %d to send the same values", plan.RandSeed, plan.RandSeed))
+	}
+	if plan.Repeat > 1 || plan.P95Under > 0 {
+		var lines []string
+		for _, timing := range plan.OperationTimings() {
+			lines = append(lines, timing.String())
+		}
+		comments = append(comments, "Latency percentiles\n"+strings.Join(lines, "\n"))
+	}
+	if sizes := plan.LargestResponses(); len(sizes) > 0 {
+		var lines []string
+		for _, size := range sizes {
+			lines = append(lines, size.String())
+		}
+		comments = append(comments, "Largest responses\n"+strings.Join(lines, "\n"))
+	}
+	p.comment = strings.Join(comments, "\n\n")
+	return p
+}
+
+// IsReadOnly returns whether the test calls a safe operation that doesn't change the server.
+func (t *Test) IsReadOnly(swagger *mqswag.Swagger) bool {
+	pathItem, ok := swagger.Paths.Paths[t.Path]
+	if !ok {
+		return false
+	}
+	method := strings.ToLower(t.Method)
+	return mqswag.IsSafeOperation(method, GetOperationByMethod(&pathItem, method))
+}
+
+// AllowsAnonymous returns whether the test calls an operation that works both with and without credentials.
+func (t *Test) AllowsAnonymous(swagger *mqswag.Swagger) bool {
+	pathItem, ok := swagger.Paths.Paths[t.Path]
+	if !ok {
+		return false
+	}
+	return swagger.AllowsAnonymous(GetOperationByMethod(&pathItem, strings.ToLower(t.Method)))
+}
+
+// IsDeprecated returns whether the test calls a deprecated operation.
+func (t *Test) IsDeprecated(swagger *mqswag.Swagger) bool {
+	pathItem, ok := swagger.Paths.Paths[t.Path]
+	if !ok {
+		return false
+	}
+	op := GetOperationByMethod(&pathItem, strings.ToLower(t.Method))
+	return op != nil && op.Deprecated
+}
+
+// FilterTests removes the tests for which keep returns false. The meqa_init and ref tests are
+// always kept. The test suites that become empty are removed. Returns the number of tests removed.
+func (plan *TestPlan) FilterTests(keep func(*Test) bool) int {
+	removed := 0
+	var suiteList []*TestSuite
+	for _, testSuite := range plan.SuiteList {
+		var tests []*Test
+		for _, test := range testSuite.Tests {
+			if test.Name == MeqaInit || len(test.Ref) > 0 || keep(test) {
+				tests = append(tests, test)
+			} else {
+				removed++
+			}
+		}
+		testSuite.Tests = tests
+		hasTest := false
+		for _, test := range tests {
+			if test.Name != MeqaInit {
+				hasTest = true
+				break
+			}
+		}
+		if hasTest || testSuite.Name == MeqaInit {
+			suiteList = append(suiteList, testSuite)
+		} else {
+			delete(plan.SuiteMap, testSuite.Name)
+		}
+	}
+	plan.SuiteList = suiteList
+	return removed
+}
+
+// FilterReadOnly removes all the tests that may change the server.
+func (plan *TestPlan) FilterReadOnly() {
+	plan.FilterTests(func(t *Test) bool { return t.IsReadOnly(plan.swagger) })
+}
+
+// FilterDeprecated removes all the tests that call deprecated operations. Returns the number of tests removed.
+func (plan *TestPlan) FilterDeprecated() int {
+	return plan.FilterTests(func(t *Test) bool { return !t.IsDeprecated(plan.swagger) })
+}
+
+// FilterTestsInFile removes the tests for which keep returns false from a test plan file, and
+// writes the result back to the same file. Returns the number of tests removed.
+func FilterTestsInFile(path string, keep func(*Test) bool) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var output []string
+	chunks := strings.Split(string(data), "---")
+	for _, chunk := range chunks {
+		var suiteMap map[string]([]*Test)
+		err := yaml.Unmarshal([]byte(chunk), &suiteMap)
+		if err != nil || len(suiteMap) == 0 {
+			// Comments only.
+			output = append(output, chunk)
+			continue
+		}
+		for suiteName, testList := range suiteMap {
+			if suiteName == MeqaInit {
+				continue
+			}
+			var tests []*Test
+			for _, t := range testList {
+				if t.Name == MeqaInit || len(t.Ref) > 0 || keep(t) {
+					tests = append(tests, t)
+				} else {
+					removed++
+				}
+			}
+			if len(tests) == 0 || (len(tests) == 1 && tests[0].Name == MeqaInit) {
+				delete(suiteMap, suiteName)
+			} else {
+				suiteMap[suiteName] = tests
+			}
+		}
+		if len(suiteMap) == 0 {
+			continue
+		}
+		chunkBytes, err := yaml.Marshal(suiteMap)
+		if err != nil {
+			return 0, err
+		}
+		output = append(output, "\n"+string(chunkBytes))
+	}
+	return removed, ioutil.WriteFile(path, []byte(strings.Join(output, "---")), 0644)
 }
 
 func (plan *TestPlan) Init(swagger *mqswag.Swagger, db *mqswag.DB) {
@@ -251,17 +725,60 @@ func (plan *TestPlan) Run(name string, parentTest *Test) error {
 		mqutil.Logger.Println(str)
 		return errors.New(str)
 	}
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
 	tc.db = plan.db.CloneSchema()
+	tc.root = tc
+	if parentTest != nil && parentTest.suite != nil && parentTest.suite.root != nil {
+		tc.root = parentTest.suite.root
+	}
+	if parentTest != nil && parentTest.suite != nil && parentTest.suite.session != nil {
+		// Referenced suites run as part of the caller.
+		tc.session = parentTest.suite.session
+	} else if plan.FreshSessionPerSuite {
+		tc.session = plan.NewSession()
+		tc.session.loginErr = plan.login(tc.session)
+	} else {
+		tc.session = plan.Session()
+	}
 	defer func() {
 		tc.db = nil
+		tc.session = nil
+		tc.root = nil
 	}()
+	if tc.session.loginErr != nil {
+		return tc.session.loginErr
+	}
 
-	for _, test := range tc.Tests {
+	// With SuiteParallel, the consecutive tests that can run at the same time are batched, the batch runs
+	// before a test that can't join it.
+	var batch []*Test
+	runBatch := func(left []*Test) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		tests := batch
+		batch = nil
+		if err := plan.runBatch(tc, tests, parentTest); err != nil {
+			return plan.stopSuite(tc, left, err)
+		}
+		return nil
+	}
+	for i, test := range tc.Tests {
+		if note := plan.stopped(); len(note) > 0 {
+			plan.skipStopped(tc, append(batch, tc.Tests[i:]...), note)
+			return nil
+		}
+		if !plan.concurrent(test, batch) {
+			if err := runBatch(tc.Tests[i:]); err != nil {
+				return err
+			}
+		}
 		if len(test.Ref) != 0 {
 			test.Strict = tc.Strict
 			err := plan.Run(test.Ref, test)
 			if err != nil {
-				return err
+				return plan.stopSuite(tc, tc.Tests[i+1:], err)
 			}
 			continue
 		}
@@ -273,24 +790,184 @@ func (plan *TestPlan) Run(name string, parentTest *Test) error {
 			continue
 		}
 
-		dup := test.Duplicate()
-		dup.Strict = tc.Strict
-		if parentTest != nil {
-			dup.CopyParent(parentTest)
+		if !plan.IncludeDeprecated && test.IsDeprecated(plan.swagger) {
+			mqutil.Logger.Printf("skipping %s, %s %s is deprecated", test.Name, test.Method, test.Path)
+			fmt.Printf("\nSkipping test case: %s (deprecated)\n", test.Name)
+			plan.mutex.Lock()
+			plan.SkippedDeprecated++
+			plan.mutex.Unlock()
+			continue
+		}
+
+		if plan.EndpointHealthCheck && !plan.EndpointReachable(tc, test) {
+			// The skipped test is listed after the batch before it.
+			if err := runBatch(tc.Tests[i:]); err != nil {
+				return err
+			}
+			mqutil.Logger.Printf("skipping %s, %s is not reachable", test.Name, collectionRoute(test.Path))
+			fmt.Printf("\nSkipping test case: %s (%s is unreachable)\n", test.Name, collectionRoute(test.Path))
+			skipped := test.Duplicate()
+			skipped.Notes = []string{NoteUnreachable}
+			plan.addResult(tc, skipped)
+			plan.mutex.Lock()
+			plan.SkippedUnreachable++
+			plan.mutex.Unlock()
+			continue
+		}
+
+		if plan.ReadOnly && !test.IsReadOnly(plan.swagger) {
+			mqutil.Logger.Printf("skipping %s, %s %s is not a read-only operation", test.Name, test.Method, test.Path)
+			fmt.Printf("\nSkipping test case: %s (not read-only)\n", test.Name)
+			continue
+		}
+
+		if existing, className := plan.existingObject(test); existing != nil {
+			plan.useExisting(tc, test, className, existing)
+			continue
+		}
+
+		if plan.concurrent(test, batch) {
+			batch = append(batch, test)
+			continue
 		}
-		dup.ResolveHistoryParameters(&History)
-		History.Append(dup)
-		if parentTest != nil {
-			dup.Name = parentTest.Name // always inherit the name
+		if len(test.Data) == 0 {
+			if err := plan.runTest(tc, test, parentTest, nil, 0); err != nil {
+				return plan.stopSuite(tc, tc.Tests[i+1:], err)
+			}
+			continue
 		}
-		err := dup.Run(tc)
-		dup.err = err
-		plan.resultList = append(plan.resultList, dup)
+		// A data-driven test runs once per row of its data file.
+		rows, err := plan.LoadData(test.Data)
 		if err != nil {
+			fmt.Printf("\nRunning test case: %s\n... Fail\n... %s\n", test.Name, err.Error())
+			failed := test.Duplicate()
+			failed.err = err
+			plan.addResult(tc, failed)
+			plan.failFast(failed)
+			return plan.stopSuite(tc, tc.Tests[i+1:], err)
+		}
+		for rowNum, row := range rows {
+			if err := plan.runTest(tc, test, parentTest, row, rowNum+1); err != nil {
+				return plan.stopSuite(tc, tc.Tests[i+1:], err)
+			}
+		}
+	}
+	return runBatch(nil)
+}
+
+// stopSuite returns the error the suite stopped at. When that stops the run, the tests of the suite that
+// are left are recorded as skipped.
+func (plan *TestPlan) stopSuite(tc *TestSuite, left []*Test, err error) error {
+	if note := plan.stopped(); len(note) > 0 {
+		plan.skipStopped(tc, left, note)
+	}
+	return err
+}
+
+// runTest runs a copy of the test. For data-driven tests, row is the data row and rowNum its 1-based
+// number in the data file.
+func (plan *TestPlan) runTest(tc *TestSuite, test *Test, parentTest *Test, row map[string]string, rowNum int) error {
+	if plan.AllContentTypes && len(test.ContentType) == 0 {
+		if types := test.ContentTypes(plan.swagger); len(types) > 1 {
+			// One case per media type the operation accepts.
+			for _, mediaType := range types {
+				variant := *test
+				variant.ContentType = mediaType
+				if err := plan.runVariants(tc, &variant, parentTest, row, rowNum); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return plan.runVariants(tc, test, parentTest, row, rowNum)
+}
+
+// runVariants runs the test, and runs it again without credentials when the plan asks for it. With
+// Negative, it also runs the negative cases of the operation.
+func (plan *TestPlan) runVariants(tc *TestSuite, test *Test, parentTest *Test, row map[string]string, rowNum int) error {
+	err := plan.runCase(tc, test, parentTest, row, rowNum, test.Anonymous)
+	if err == nil && plan.Negative {
+		plan.runNegatives(tc, test, parentTest)
+	}
+	if err != nil || test.Anonymous || !plan.AnonymousAccess || !test.AllowsAnonymous(plan.swagger) {
+		return err
+	}
+	// The operation is optionally authenticated, check that it also works without credentials.
+	return plan.runCase(tc, test, parentTest, row, rowNum, true)
+}
+
+func (plan *TestPlan) runCase(tc *TestSuite, test *Test, parentTest *Test, row map[string]string, rowNum int, anonymous bool) error {
+	dup := test.Duplicate()
+	dup.Anonymous = anonymous
+	dup.Strict = tc.Strict
+	if parentTest != nil {
+		dup.CopyParent(parentTest)
+	}
+	if row != nil {
+		dup.Row = rowNum
+		if err := dup.ApplyDataRow(plan.swagger, row); err != nil {
+			dup.err = err
+			plan.addResult(tc, dup)
+			plan.failFast(dup)
 			return err
 		}
 	}
-	return nil
+	dup.ResolveHistoryParameters(&History)
+	History.Append(dup)
+	if parentTest != nil {
+		dup.Name = parentTest.Name // always inherit the name
+	}
+	err := dup.Run(tc)
+	dup.err = err
+	plan.addResult(tc, dup)
+	if err != nil {
+		plan.failFast(dup)
+	}
+	return err
+}
+
+// addResult appends the test to the results of the run, noting the top level suite it ran in.
+func (plan *TestPlan) addResult(tc *TestSuite, t *Test) {
+	t.root = tc.root
+	if t.batch != nil {
+		t.batch.add(t)
+		return
+	}
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	plan.resultList = append(plan.resultList, t)
+}
+
+// ResultCount returns the number of tests in the last run.
+func (plan *TestPlan) ResultCount() int {
+	return len(plan.resultList)
+}
+
+// AmbiguousTests returns the tests in the last run that passed without being fully verified: the ones
+// skipped because their endpoint is unreachable, and the ones whose response had no schema to check.
+func (plan *TestPlan) AmbiguousTests() []*Test {
+	var ambiguous []*Test
+	for _, test := range plan.resultList {
+		for _, note := range test.Notes {
+			if note == NoteUnreachable || note == NoteNoSchema || note == NoteDeadline || note == NoteInterrupted {
+				ambiguous = append(ambiguous, test)
+				break
+			}
+		}
+	}
+	return ambiguous
+}
+
+// FailedTests returns the tests in the last run that failed.
+func (plan *TestPlan) FailedTests() []*Test {
+	var failed []*Test
+	for _, test := range plan.resultList {
+		if test.err != nil {
+			failed = append(failed, test)
+		}
+	}
+	return failed
 }
 
 // The current global TestPlan
@@ -0,0 +1,64 @@
+package mqplan
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"meqa/mqutil"
+)
+
+func TestVariables(t *testing.T) {
+	os.Setenv("MQPLAN_TEST_ENV", "env")
+	os.Setenv("MQPLAN_TEST_OWNER", "env-owner")
+	defer os.Unsetenv("MQPLAN_TEST_ENV")
+	defer os.Unsetenv("MQPLAN_TEST_OWNER")
+
+	plan := &TestPlan{Variables: map[string]string{"MQPLAN_TEST_TENANT": "cli-tenant"}}
+	vars := plan.resolveVariables(map[string]string{
+		"MQPLAN_TEST_OWNER":  "plan-owner",
+		"MQPLAN_TEST_TENANT": "plan-tenant",
+		"MQPLAN_TEST_LIMIT":  "10",
+	})
+	expected := map[string]string{
+		"MQPLAN_TEST_OWNER":  "env-owner",
+		"MQPLAN_TEST_TENANT": "cli-tenant",
+		"MQPLAN_TEST_LIMIT":  "10",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("got the variables %v, expecting %v", vars, expected)
+	}
+
+	cases := []struct {
+		text    string
+		result  string
+		missing []string
+	}{
+		{"limit: ${MQPLAN_TEST_LIMIT}", "limit: 10", nil},
+		{"owner: ${MQPLAN_TEST_OWNER}, tenant: ${MQPLAN_TEST_TENANT}", "owner: env-owner, tenant: cli-tenant", nil},
+		{"path: ${MQPLAN_TEST_ENV}/x", "path: env/x", nil},
+		{"literal: $${MQPLAN_TEST_LIMIT}", "literal: ${MQPLAN_TEST_LIMIT}", nil},
+		{"literal: $${MQPLAN_TEST_UNSET}", "literal: ${MQPLAN_TEST_UNSET}", nil},
+		{"a: ${MQPLAN_TEST_UNSET}, b: ${MQPLAN_TEST_LIMIT}, c: ${MQPLAN_TEST_OTHER}",
+			"a: ${MQPLAN_TEST_UNSET}, b: 10, c: ${MQPLAN_TEST_OTHER}", []string{"MQPLAN_TEST_OTHER", "MQPLAN_TEST_UNSET"}},
+		{"not a variable: $MQPLAN_TEST_LIMIT {MQPLAN_TEST_LIMIT}", "not a variable: $MQPLAN_TEST_LIMIT {MQPLAN_TEST_LIMIT}", nil},
+	}
+	for _, c := range cases {
+		missing := make(map[string]bool)
+		if result := substituteVariables(c.text, vars, missing); result != c.result {
+			t.Errorf("substituteVariables(%s) = %s, expecting %s", c.text, result, c.result)
+		}
+		expectedMissing := make(map[string]bool)
+		for _, name := range c.missing {
+			expectedMissing[name] = true
+		}
+		if !reflect.DeepEqual(missing, expectedMissing) {
+			t.Errorf("substituteVariables(%s) missing %v, expecting %v", c.text, missing, c.missing)
+		}
+	}
+}
+
+func TestMain(m *testing.M) {
+	mqutil.Logger = mqutil.NewStdLogger()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,235 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type postmanKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanURL struct {
+	Raw string `json:"raw"`
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	URLEncoded []postmanKeyValue `json:"urlencoded,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	URL    postmanURL        `json:"url"`
+	Body   *postmanBody      `json:"body,omitempty"`
+}
+
+type postmanScript struct {
+	Type string   `json:"type"`
+	Exec []string `json:"exec"`
+}
+
+type postmanEvent struct {
+	Listen string        `json:"listen"`
+	Script postmanScript `json:"script"`
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+	Event   []postmanEvent `json:"event,omitempty"`
+}
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []*postmanItem    `json:"item"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+}
+
+var postmanNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func postmanVariable(name string) string {
+	return postmanNameRegex.ReplaceAllString(name, "_")
+}
+
+// postmanExporter turns the values that came from the responses of earlier tests into collection
+// variables, which are set by the test scripts of the earlier requests.
+type postmanExporter struct {
+	tests     []*Test
+	items     []*postmanItem
+	variables map[string]string
+	scripts   map[int]map[string]string // test index to the variables its response sets
+}
+
+// chain finds the variable for a value the i-th test used. Only the top level fields of the earlier
+// responses are considered.
+func (e *postmanExporter) chain(i int, value interface{}) (string, bool) {
+	str := fmt.Sprint(value)
+	if value == nil || len(str) == 0 {
+		return "", false
+	}
+	for j := i - 1; j >= 0; j-- {
+		body, ok := e.tests[j].Expect[ExpectBody].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(body))
+		for k := range body {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if fmt.Sprint(body[k]) == str {
+				name := postmanVariable(e.tests[j].Name + "_" + k)
+				if e.scripts[j] == nil {
+					e.scripts[j] = make(map[string]string)
+				}
+				e.scripts[j][name] = k
+				return "{{" + name + "}}", true
+			}
+		}
+	}
+	return "", false
+}
+
+func (e *postmanExporter) valueOf(i int, value interface{}) string {
+	if v, ok := e.chain(i, value); ok {
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// chainBody returns a copy of the body with the values that came from earlier responses replaced by
+// their variables. The variables that stand for numbers are recorded in unquote, since postman substitutes
+// them in the raw text, where they mustn't be json strings.
+func (e *postmanExporter) chainBody(i int, body interface{}, unquote map[string]bool) interface{} {
+	switch b := body.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(b))
+		for k, v := range b {
+			m[k] = e.chainBody(i, v, unquote)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(b))
+		for j, v := range b {
+			a[j] = e.chainBody(i, v, unquote)
+		}
+		return a
+	case bool:
+		// True and false are too common to have come from a response.
+		return body
+	}
+	v, ok := e.chain(i, body)
+	if !ok {
+		return body
+	}
+	if _, isString := body.(string); !isString {
+		unquote[v] = true
+	}
+	return v
+}
+
+func (e *postmanExporter) addItem(i int, t *Test, baseURL string) {
+	item := &postmanItem{Name: t.Name}
+	item.Request.Method = t.request.Method
+
+	path := t.Path
+	for k, v := range t.PathParams {
+		path = strings.Replace(path, "{"+k+"}", e.valueOf(i, v), -1)
+	}
+	var query []string
+	for k, v := range t.QueryParams {
+		query = append(query, url.QueryEscape(k)+"="+e.valueOf(i, v))
+	}
+	sort.Strings(query)
+	item.Request.URL.Raw = baseURL + path
+	if len(query) > 0 {
+		item.Request.URL.Raw += "?" + strings.Join(query, "&")
+	}
+
+	for k, values := range t.request.Headers {
+		for _, v := range values {
+			if v == redacted {
+				// Let the user fill in the credentials.
+				v = "{{" + postmanVariable(k) + "}}"
+				e.variables[postmanVariable(k)] = ""
+			}
+			item.Request.Header = append(item.Request.Header, postmanKeyValue{k, v})
+		}
+	}
+	sort.Slice(item.Request.Header, func(a, b int) bool { return item.Request.Header[a].Key < item.Request.Header[b].Key })
+
+	if t.BodyParams != nil {
+		unquote := make(map[string]bool)
+		data, _ := json.MarshalIndent(e.chainBody(i, t.request.Body, unquote), "", "  ")
+		raw := string(data)
+		for v := range unquote {
+			raw = strings.Replace(raw, `"`+v+`"`, v, -1)
+		}
+		item.Request.Body = &postmanBody{Mode: "raw", Raw: raw}
+	} else if len(t.FormParams) > 0 {
+		item.Request.Body = &postmanBody{Mode: "urlencoded"}
+		for k, v := range t.FormParams {
+			item.Request.Body.URLEncoded = append(item.Request.Body.URLEncoded, postmanKeyValue{k, e.valueOf(i, v)})
+		}
+	}
+	e.items = append(e.items, item)
+}
+
+// WritePostmanCollection writes the requests of the last run to a Postman v2.1 collection file. The values
+// in the paths, queries, forms and bodies that were taken from earlier responses become collection variables.
+func (plan *TestPlan) WritePostmanCollection(path string) error {
+	baseURL := plan.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = GetBaseURL(plan.swagger)
+	}
+	e := &postmanExporter{variables: make(map[string]string), scripts: make(map[int]map[string]string)}
+	for _, t := range plan.resultList {
+		if t.request != nil {
+			e.tests = append(e.tests, t)
+		}
+	}
+	for i, t := range e.tests {
+		e.addItem(i, t, baseURL)
+	}
+	for i, vars := range e.scripts {
+		var exec []string
+		for name, field := range vars {
+			exec = append(exec, fmt.Sprintf("pm.collectionVariables.set(%q, pm.response.json()[%q]);", name, field))
+			e.variables[name] = ""
+		}
+		sort.Strings(exec)
+		e.items[i].Event = []postmanEvent{{"test", postmanScript{"text/javascript", exec}}}
+	}
+
+	c := postmanCollection{Item: e.items}
+	c.Info.Name = "meqa"
+	if plan.swagger.Info != nil && len(plan.swagger.Info.Title) > 0 {
+		c.Info.Name = plan.swagger.Info.Title
+	}
+	c.Info.Schema = postmanSchema
+	for k, v := range e.variables {
+		c.Variable = append(c.Variable, postmanKeyValue{k, v})
+	}
+	sort.Slice(c.Variable, func(a, b int) bool { return c.Variable[a].Key < c.Variable[b].Key })
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
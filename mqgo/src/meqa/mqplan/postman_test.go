@@ -0,0 +1,108 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"meqa/mqswag"
+)
+
+func TestWritePostmanCollection(t *testing.T) {
+	create := &Test{Name: "create", Path: "/pet", Method: "post"}
+	create.BodyParams = map[string]interface{}{"name": "rex"}
+	create.Expect = map[string]interface{}{ExpectBody: map[string]interface{}{"id": float64(7), "name": "rex", "status": "available"}}
+	create.request = &requestRecord{Method: "POST", Body: create.BodyParams}
+
+	// The id and status of the create are chained, in the path, the query, the nested body fields and the arrays.
+	update := &Test{Name: "update", Path: "/pet/{petId}", Method: "put"}
+	update.PathParams = map[string]interface{}{"petId": float64(7)}
+	update.QueryParams = map[string]interface{}{"status": "available"}
+	update.BodyParams = map[string]interface{}{
+		"id":         float64(7),
+		"name":       "rex2",
+		"status":     "available",
+		"owner":      map[string]interface{}{"petId": float64(7)},
+		"friends":    []interface{}{float64(7), float64(8)},
+		"vaccinated": true,
+	}
+	update.request = &requestRecord{Method: "PUT", Headers: map[string][]string{"Authorization": {redacted}}, Body: update.BodyParams}
+
+	adopt := &Test{Name: "adopt", Path: "/adopt", Method: "post"}
+	adopt.FormParams = map[string]interface{}{"petId": float64(7)}
+	adopt.request = &requestRecord{Method: "POST", Body: adopt.FormParams}
+
+	plan := &TestPlan{BaseURL: "http://localhost:8080/v2", swagger: &mqswag.Swagger{}}
+	plan.resultList = []*Test{create, update, {Name: "not run"}, adopt}
+
+	dir, err := ioutil.TempDir("", "mqplan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "postman.json")
+	if err = plan.WritePostmanCollection(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c postmanCollection
+	if err = json.Unmarshal(data, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Item) != 3 {
+		t.Fatalf("got %d items, expecting 3", len(c.Item))
+	}
+	if body := c.Item[0].Request.Body; body == nil || body.Raw != "{\n  \"name\": \"rex\"\n}" {
+		t.Errorf("got the create body %v", body)
+	}
+	exec := []string{
+		`pm.collectionVariables.set("create_id", pm.response.json()["id"]);`,
+		`pm.collectionVariables.set("create_status", pm.response.json()["status"]);`,
+	}
+	if len(c.Item[0].Event) != 1 || !reflect.DeepEqual(c.Item[0].Event[0].Script.Exec, exec) {
+		t.Errorf("got the create events %v, expecting %v", c.Item[0].Event, exec)
+	}
+
+	item := c.Item[1]
+	if item.Request.Method != "PUT" || item.Request.URL.Raw != "http://localhost:8080/v2/pet/{{create_id}}?status={{create_status}}" {
+		t.Errorf("got the update request %s %s", item.Request.Method, item.Request.URL.Raw)
+	}
+	if !reflect.DeepEqual(item.Request.Header, []postmanKeyValue{{"Authorization", "{{Authorization}}"}}) {
+		t.Errorf("got the update headers %v", item.Request.Header)
+	}
+	raw := `{
+  "friends": [
+    {{create_id}},
+    8
+  ],
+  "id": {{create_id}},
+  "name": "rex2",
+  "owner": {
+    "petId": {{create_id}}
+  },
+  "status": "{{create_status}}",
+  "vaccinated": true
+}`
+	if item.Request.Body == nil || item.Request.Body.Mode != "raw" || item.Request.Body.Raw != raw {
+		t.Errorf("got the update body %v, expecting %s", item.Request.Body, raw)
+	}
+
+	if body := c.Item[2].Request.Body; body == nil || !reflect.DeepEqual(body.URLEncoded, []postmanKeyValue{{"petId", "{{create_id}}"}}) {
+		t.Errorf("got the adopt body %v", body)
+	}
+
+	variables := []postmanKeyValue{{"Authorization", ""}, {"create_id", ""}, {"create_status", ""}}
+	if !reflect.DeepEqual(c.Variable, variables) {
+		t.Errorf("got the variables %v, expecting %v", c.Variable, variables)
+	}
+	if c.Info.Name != "meqa" || c.Info.Schema != postmanSchema {
+		t.Errorf("got the info %v", c.Info)
+	}
+}
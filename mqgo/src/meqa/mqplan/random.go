@@ -0,0 +1,57 @@
+package mqplan
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"meqa/mqutil"
+)
+
+// SeedRandom seeds the random source of the generated parameters and objects with the plan's RandSeed,
+// so that a run with the same seed sends the same requests. Without a RandSeed it takes one from the
+// clock, and keeps it in RandSeed so that the run can be replayed.
+func (plan *TestPlan) SeedRandom() int64 {
+	if plan.RandSeed == 0 {
+		plan.RandSeed = time.Now().UnixNano()
+	}
+	rand.Seed(plan.RandSeed)
+	timeBase = seedTimeBase(plan.RandSeed)
+	mqutil.Logger.Printf("random seed: %d", plan.RandSeed)
+	return plan.RandSeed
+}
+
+// randomUUID returns a version 4 uuid from the seeded random source.
+func randomUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// The time the generated dates go back from, set by SeedRandom.
+var timeBase time.Time
+
+// The time base of the seeds that aren't a reading of the clock, e.g. -randseed 42.
+var fixedTimeBase = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedTimeBase returns the time base of the seed, so that a replay with the seed sends the same dates on
+// any day. The seeds taken from the clock are the time of the run in nanoseconds, their base is the start
+// of that day.
+func seedTimeBase(seed int64) time.Time {
+	t := time.Unix(0, seed).UTC()
+	if t.Before(fixedTimeBase) {
+		return fixedTimeBase
+	}
+	return t.Truncate(24 * time.Hour)
+}
+
+// randomTimeBase returns the time the generated dates go back from, the start of the day when the
+// random source wasn't seeded by SeedRandom.
+func randomTimeBase() time.Time {
+	if timeBase.IsZero() {
+		return time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	return timeBase
+}
@@ -0,0 +1,59 @@
+package mqplan
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The note on tests skipped because the endpoint preflight couldn't reach their route.
+const NoteUnreachable = "unreachable"
+
+// collectionRoute returns the part of the path before the first path parameter, e.g. /store/order for
+// /store/order/{orderId}.
+func collectionRoute(path string) string {
+	if i := strings.Index(path, "{"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSuffix(path, "/")
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
+
+// routeReachable sends an OPTIONS to the route, and a GET when that's not found. Any response other than
+// 404 means the route exists in the deployment, even if it's an error like 401 or 405.
+func routeReachable(tc *TestSuite, url string) bool {
+	resp, err := tc.R().Options(url)
+	if err == nil && resp.StatusCode() != http.StatusNotFound {
+		return true
+	}
+	resp, err = tc.R().Get(url)
+	return err == nil && resp.StatusCode() != http.StatusNotFound
+}
+
+// EndpointReachable runs the endpoint preflight for the test's route, once per route in the run.
+func (plan *TestPlan) EndpointReachable(tc *TestSuite, t *Test) bool {
+	if plan.dryRun != nil {
+		return true
+	}
+	route := collectionRoute(t.Path)
+	plan.mutex.Lock()
+	reachable, ok := plan.reachableRoutes[route]
+	plan.mutex.Unlock()
+	if ok {
+		return reachable
+	}
+	baseURL := plan.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = GetBaseURL(plan.swagger)
+	}
+	reachable = routeReachable(tc, baseURL+route)
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if plan.reachableRoutes == nil {
+		plan.reachableRoutes = make(map[string]bool)
+	}
+	plan.reachableRoutes[route] = reachable
+	return reachable
+}
@@ -0,0 +1,61 @@
+package mqplan
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// WaitForReady polls the health url until it returns a 2xx status, or until the timeout expires.
+func WaitForReady(url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := resty.R().Get(url)
+		if err == nil && resp.StatusCode() >= 200 && resp.StatusCode() < 300 {
+			return nil
+		}
+		if err != nil {
+			mqutil.Logger.Printf("waiting for %s: %s", url, err.Error())
+		} else {
+			mqutil.Logger.Printf("waiting for %s: got status %d", url, resp.StatusCode())
+		}
+		if time.Now().After(deadline) {
+			return mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("%s is not ready after %v", url, timeout))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// TransportFailures returns the number of tests in the last run that failed because the REST call itself
+// failed (e.g. connection refused), and the total number of tests run.
+func (plan *TestPlan) TransportFailures() (int, int) {
+	count := 0
+	for _, test := range plan.resultList {
+		if typedErr, ok := test.err.(mqutil.Error); ok && typedErr.Type() == mqutil.ErrHttp {
+			count++
+		}
+	}
+	return count, len(plan.resultList)
+}
+
+// ResetRun clears the results and the history of the last run, so that the plan can be run again.
+func (plan *TestPlan) ResetRun() {
+	plan.resultList = nil
+	plan.session = nil
+	plan.reachableRoutes = nil
+	plan.negativeOps = nil
+	plan.SkippedUnreachable = 0
+	plan.SkippedDeadline = 0
+	plan.SkippedExisting = 0
+	plan.SkippedRetries = 0
+	plan.SkippedFailFast = 0
+	plan.SkippedInterrupted = 0
+	plan.FirstFailure = ""
+	plan.stopNote = ""
+	History.mutex.Lock()
+	History.tests = nil
+	History.mutex.Unlock()
+}
@@ -0,0 +1,65 @@
+package mqplan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"meqa/mqutil"
+)
+
+// The note on tests whose suite -rerun leaves out, because it passed in the earlier result file.
+const NotePassedPreviously = "passed-previously"
+
+// resultTest is what -rerun reads of a test in a result file.
+type resultTest struct {
+	Name  string `yaml:"name"`
+	Suite string `yaml:"suite"`
+	Error string `yaml:"error"`
+}
+
+// LoadRerun reads the yaml result file of an earlier run, and returns the top level suites of its tests,
+// true for the ones with a failed test. The suites are rerun whole, so that the steps a failed test
+// depends on, like the create before it, run again too.
+func LoadRerun(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't read the result file %s: %s", path, err.Error()))
+	}
+	suites := make(map[string]bool)
+	for _, chunk := range strings.Split(string(data), "---") {
+		var results map[string][]*resultTest
+		if err := yaml.Unmarshal([]byte(chunk), &results); err != nil {
+			return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse the result file %s: %s", path, err.Error()))
+		}
+		for _, tests := range results {
+			for _, test := range tests {
+				if test == nil || len(test.Suite) == 0 {
+					continue
+				}
+				suites[test.Suite] = suites[test.Suite] || len(test.Error) > 0
+			}
+		}
+	}
+	if len(suites) == 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"the result file %s has no tests with their suite, it may be from an older mqgo", path))
+	}
+	return suites, nil
+}
+
+// reruns returns whether the suite runs with -rerun, it had a failed test in the earlier result file.
+func (plan *TestPlan) reruns(tc *TestSuite) bool {
+	return plan.Rerun == nil || plan.Rerun[tc.Name]
+}
+
+// filteredNote returns the note on the tests of a suite that doesn't run: passed-previously when it
+// passed in the result file of -rerun, filtered otherwise.
+func (plan *TestPlan) filteredNote(tc *TestSuite) string {
+	if failed, ok := plan.Rerun[tc.Name]; ok && !failed {
+		return NotePassedPreviously
+	}
+	return NoteFiltered
+}
@@ -0,0 +1,99 @@
+package mqplan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The result file of a run where the get of the pets failed after its create passed. It's in the flow
+// style of yaml, with the two documents of a run grouped by tag.
+const rerunResult = `{"pet": [
+  {"name": "create", "path": "/pet", "method": "post", "suite": "pets"},
+  {"name": "get", "path": "/pet/{petId}", "method": "get", "suite": "pets", "error": "got status 500"},
+  {"name": "older", "path": "/pet", "method": "get"}
+]}
+---
+{"user": [
+  {"name": "create", "path": "/user", "method": "post", "suite": "users"},
+  {"name": "list", "path": "/user", "method": "get", "suite": "users"},
+  {"name": "create", "path": "/store/order", "method": "post", "suite": "orders"}
+]}
+`
+
+func TestRerun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mqplan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "result.yaml")
+	if err = ioutil.WriteFile(path, []byte(rerunResult), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rerun, err := LoadRerun(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"pets": true, "users": false, "orders": false}
+	if !reflect.DeepEqual(rerun, expected) {
+		t.Errorf("got the suites %v, expecting %v", rerun, expected)
+	}
+
+	plan := &TestPlan{}
+	plan.Init(nil, nil)
+	for _, suite := range []string{
+		`{"pets": [{"name": "create", "path": "/pet", "method": "post"}, {"name": "get", "path": "/pet/{petId}", "method": "get"}]}`,
+		`{"users": [{"name": "create", "path": "/user", "method": "post"}, {"name": "list", "path": "/user", "method": "get"}]}`,
+		`{"orders": [{"name": "create", "path": "/store/order", "method": "post"}]}`,
+		`{"stores": [{"name": "list", "path": "/store", "method": "get"}]}`,
+	} {
+		if err = plan.AddFromString(suite); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plan.Rerun = rerun
+
+	// The pets suite runs whole, so its create runs again before the get that failed.
+	selected, filtered, err := plan.SelectSuites("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []string{"pets"}) {
+		t.Errorf("selected %v, expecting [pets]", selected)
+	}
+	var tests []string
+	for _, test := range plan.SuiteMap["pets"].Tests {
+		tests = append(tests, test.Name)
+	}
+	if !reflect.DeepEqual(tests, []string{"create", "get"}) {
+		t.Errorf("the pets suite has %v, expecting [create get]", tests)
+	}
+
+	// The others are in the result file as skipped, the ones that passed noted so.
+	plan.SkipFiltered(filtered)
+	notes := make(map[string][]string)
+	for _, test := range plan.resultList {
+		notes[test.root.Name+"/"+test.Name] = test.Notes
+	}
+	expectedNotes := map[string][]string{
+		"users/create":  {NotePassedPreviously},
+		"users/list":    {NotePassedPreviously},
+		"orders/create": {NotePassedPreviously},
+		"stores/list":   {NoteFiltered},
+	}
+	if !reflect.DeepEqual(notes, expectedNotes) {
+		t.Errorf("got the notes %v, expecting %v", notes, expectedNotes)
+	}
+
+	// A result file without the suites can't be rerun.
+	if err = ioutil.WriteFile(path, []byte(`{"pet": [{"name": "older", "path": "/pet", "method": "get"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadRerun(path); err == nil {
+		t.Errorf("expecting an error for a result file without the suites")
+	}
+}
@@ -0,0 +1,169 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// The wait before the first retry when -retry-wait isn't given. It doubles with every retry.
+const defaultRetryWait = 500 * time.Millisecond
+
+// The note on tests whose call wasn't retried, as the retries couldn't finish before the run's deadline.
+const NoteRetryBudget = "retry-budget"
+
+// The longest wait between two retries.
+const maxRetryWait = 30 * time.Second
+
+// call sends the request with the test's method.
+func (t *Test) call(req *resty.Request, path string) (*resty.Response, error) {
+	switch t.Method {
+	case mqswag.MethodGet:
+		return req.Get(path)
+	case mqswag.MethodPost:
+		return req.Post(path)
+	case mqswag.MethodPut:
+		return req.Put(path)
+	case mqswag.MethodDelete:
+		return req.Delete(path)
+	case mqswag.MethodPatch:
+		return req.Patch(path)
+	case mqswag.MethodHead:
+		return req.Head(path)
+	case mqswag.MethodOptions:
+		return req.Options(path)
+	}
+	return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("Unknown method in test %s: %v", t.Name, t.Method))
+}
+
+func (t *Test) knownMethod() bool {
+	for _, method := range mqswag.MethodAll {
+		if t.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable returns whether the failed call may be sent again. The idempotent methods are retried on
+// connection errors and 5xx responses. The post and patch calls may have created or changed objects on
+// the server even when it failed, so they are only retried when no response was received at all.
+func (t *Test) retryable(plan *TestPlan, resp *resty.Response, err error) bool {
+	if !t.knownMethod() {
+		return false
+	}
+	if err != nil {
+		return plan.responseTooLarge(err) == nil && (resp == nil || resp.RawResponse == nil)
+	}
+	if resp == nil || resp.StatusCode() < 500 {
+		return false
+	}
+	return t.Method != mqswag.MethodPost && t.Method != mqswag.MethodPatch
+}
+
+// retryWait returns how long to wait before the retry, the nth starting from 0. The wait doubles with
+// every retry, with a random jitter so that parallel tests don't retry in lockstep.
+func (plan *TestPlan) retryWait(n int) time.Duration {
+	wait := plan.RetryWait
+	if wait <= 0 {
+		wait = defaultRetryWait
+	}
+	for i := 0; i < n && wait < maxRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	// The jitter comes from the clock, drawing it from the seeded source would change the generated values.
+	return wait/2 + time.Duration(time.Now().UnixNano()%(int64(wait/2)+1))
+}
+
+// The longest Retry-After of a 429 we wait for, and how many times we wait for one in a call.
+const maxRetryAfter = time.Minute
+const maxThrottledRetries = 5
+
+// retryAfter returns how long the 429 response asks us to wait before calling again. The Retry-After
+// header is either a number of seconds or an HTTP date.
+func retryAfter(resp *resty.Response) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode() != http.StatusTooManyRequests {
+		return 0, false
+	}
+	header := strings.TrimSpace(resp.Header().Get("Retry-After"))
+	if len(header) == 0 {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(time.Now())
+	} else {
+		return 0, false
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, wait <= maxRetryAfter
+}
+
+// callWithRetries sends the request, retrying the transient failures up to the plan's Retries times. With
+// a rate limit, the calls wait for their turn. The 429s with a Retry-After are sent again after that long,
+// without counting toward Retries, and with a rate limit all the calls are paused meanwhile. The number of retries taken, of both
+// kinds, is recorded on the test. A retry isn't started when the wait and another call as long as the last
+// one would go past the plan's deadline, the test fails with the last attempt instead.
+func (t *Test) callWithRetries(tc *TestSuite, req *resty.Request, path string) (*resty.Response, error) {
+	limiter := tc.plan.RateLimiter
+	retries, throttled := 0, 0
+	for {
+		if limiter != nil {
+			limiter.Wait()
+		}
+		t.startTime = time.Now()
+		resp, err := t.call(req, path)
+		if err == nil && throttled < maxThrottledRetries {
+			if wait, ok := retryAfter(resp); ok {
+				if limiter != nil {
+					fmt.Printf("... got %s, pausing the calls for %v as asked by Retry-After\n", resp.Status(), wait)
+					mqutil.Logger.Printf("got %s, pausing the calls for %v", resp.Status(), wait)
+					limiter.Pause(wait)
+				} else {
+					fmt.Printf("... got %s, retrying in %v as asked by Retry-After\n", resp.Status(), wait)
+					mqutil.Logger.Printf("got %s, retrying %s in %v", resp.Status(), t.Name, wait)
+					time.Sleep(wait)
+				}
+				throttled++
+				t.Retries++
+				continue
+			}
+		}
+		if retries >= tc.plan.Retries || !t.retryable(tc.plan, resp, err) {
+			return resp, err
+		}
+		wait := tc.plan.retryWait(retries)
+		if deadline := tc.plan.Deadline; !deadline.IsZero() && time.Now().Add(wait+time.Since(t.startTime)).After(deadline) {
+			left := tc.plan.Retries - retries
+			fmt.Printf("... not retrying, the %d retries left can't finish before the -deadline\n", left)
+			mqutil.Logger.Printf("not retrying %s, the %d retries left can't finish before the deadline", t.Name, left)
+			t.Notes = append(t.Notes, NoteRetryBudget)
+			tc.plan.mutex.Lock()
+			tc.plan.SkippedRetries += left
+			tc.plan.mutex.Unlock()
+			return resp, err
+		}
+		if err != nil {
+			fmt.Printf("... %s, retrying in %v\n", err.Error(), wait)
+		} else {
+			fmt.Printf("... got %s, retrying in %v\n", resp.Status(), wait)
+		}
+		time.Sleep(wait)
+		retries++
+		t.Retries++
+	}
+}
@@ -0,0 +1,48 @@
+package mqplan
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gopkg.in/resty.v0"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now().UTC()
+	cases := []struct {
+		status int
+		header string
+		min    time.Duration
+		max    time.Duration
+		ok     bool
+	}{
+		{http.StatusTooManyRequests, "3", 3 * time.Second, 3 * time.Second, true},
+		{http.StatusTooManyRequests, " 0 ", 0, 0, true},
+		{http.StatusTooManyRequests, "60", time.Minute, time.Minute, true},
+		{http.StatusTooManyRequests, "61", 0, 0, false},
+		{http.StatusTooManyRequests, "3600", 0, 0, false},
+		{http.StatusTooManyRequests, "-5", 0, 0, true},
+		{http.StatusTooManyRequests, now.Add(30 * time.Second).Format(http.TimeFormat), 28 * time.Second, 30 * time.Second, true},
+		{http.StatusTooManyRequests, now.Add(-time.Hour).Format(http.TimeFormat), 0, 0, true},
+		{http.StatusTooManyRequests, now.Add(time.Hour).Format(http.TimeFormat), 0, 0, false},
+		{http.StatusTooManyRequests, "soon", 0, 0, false},
+		{http.StatusTooManyRequests, "", 0, 0, false},
+		{http.StatusServiceUnavailable, "3", 0, 0, false},
+		{http.StatusOK, "3", 0, 0, false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if len(c.header) > 0 {
+			header.Set("Retry-After", c.header)
+		}
+		resp := &resty.Response{RawResponse: &http.Response{StatusCode: c.status, Header: header}}
+		wait, ok := retryAfter(resp)
+		if ok != c.ok || (ok && (wait < c.min || wait > c.max)) {
+			t.Errorf("retryAfter(%d, %q) = %v, %t, expecting %v to %v, %t", c.status, c.header, wait, ok, c.min, c.max, c.ok)
+		}
+	}
+	if _, ok := retryAfter(nil); ok {
+		t.Errorf("expecting no wait without a response")
+	}
+}
@@ -0,0 +1,177 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// cannedResponse is what the self test server returns for one call of an operation.
+type cannedResponse struct {
+	status int
+	body   interface{}
+}
+
+// SelfTestServer serves canned responses keyed by operation. The responses come from the expect
+// sections of the test plan. When the plan doesn't have one we generate a mock response from the
+// swagger response schema.
+type SelfTestServer struct {
+	*httptest.Server
+
+	plan      *TestPlan
+	responses map[string][]cannedResponse // operation key to the list of canned responses
+	calls     map[string]int              // operation key to the number of times it's called
+	mockOnly  bool                        // always generate the responses, for dry runs
+	mutex     sync.Mutex
+}
+
+func operationKey(method string, path string) string {
+	return strings.ToLower(method) + " " + path
+}
+
+// NewSelfTestServer starts a stub server for the plan. The caller should point the plan's
+// BaseURL to the returned server's BaseURL and Close the server when done.
+func NewSelfTestServer(plan *TestPlan) *SelfTestServer {
+	s := &SelfTestServer{plan: plan}
+	s.responses = make(map[string][]cannedResponse)
+	s.calls = make(map[string]int)
+	for _, testSuite := range plan.SuiteList {
+		for _, test := range testSuite.Tests {
+			if len(test.Path) == 0 || len(test.Method) == 0 || test.Expect == nil {
+				continue
+			}
+			r := cannedResponse{http.StatusOK, test.Expect[ExpectBody]}
+			switch status := test.Expect[ExpectStatus].(type) {
+			case int:
+				r.status = status
+			case string:
+				if status == "fail" {
+					r.status = http.StatusNotFound
+				}
+			}
+			key := operationKey(test.Method, test.Path)
+			s.responses[key] = append(s.responses[key], r)
+		}
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL is the URL the runner should use instead of the swagger's host.
+func (s *SelfTestServer) BaseURL() string {
+	return s.URL + s.plan.swagger.BasePath
+}
+
+// matchPath finds the swagger path template that matches the request path.
+func matchPath(swagger *mqswag.Swagger, requestPath string) string {
+	reqArray := strings.Split(strings.Trim(requestPath, "/"), "/")
+	var found []string
+	for pathName := range swagger.Paths.Paths {
+		nameArray := strings.Split(strings.Trim(pathName, "/"), "/")
+		if len(nameArray) != len(reqArray) {
+			continue
+		}
+		matches := true
+		for i, n := range nameArray {
+			if len(n) > 0 && n[0] == '{' && n[len(n)-1] == '}' {
+				continue
+			}
+			if n != reqArray[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			found = append(found, pathName)
+		}
+	}
+	if len(found) == 0 {
+		return ""
+	}
+	// The path with the least parameters is the most specific one.
+	sort.Slice(found, func(i, j int) bool {
+		return strings.Count(found[i], "{") < strings.Count(found[j], "{")
+	})
+	return found[0]
+}
+
+// mockResponse generates a response from the operation's success response schema.
+func (s *SelfTestServer) mockResponse(op *spec.Operation) cannedResponse {
+	r := cannedResponse{http.StatusOK, nil}
+	if op.Responses == nil {
+		return r
+	}
+	var codes []int
+	for code := range op.Responses.StatusCodeResponses {
+		if code >= 200 && code < 300 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	var respSpec *spec.Response
+	if len(codes) > 0 {
+		r.status = codes[0]
+		resp := op.Responses.StatusCodeResponses[codes[0]]
+		respSpec = &resp
+	} else {
+		respSpec = op.Responses.Default
+	}
+	if respSpec == nil || respSpec.Schema == nil {
+		return r
+	}
+
+	t := &Test{}
+	t.comparisons = make(map[string]([]*Comparison))
+	t.db = s.plan.db
+	t.suite = CreateTestSuite("selftest", nil, s.plan)
+	t.suite.db = s.plan.db.CloneSchema()
+	body, err := t.GenerateSchema("", nil, respSpec.Schema, t.db, 0)
+	if err != nil {
+		mqutil.Logger.Printf("selftest: can't generate response: %s", err.Error())
+		return r
+	}
+	r.body = body
+	return r
+}
+
+func (s *SelfTestServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	requestPath := strings.TrimPrefix(req.URL.Path, s.plan.swagger.BasePath)
+	pathName := matchPath(s.plan.swagger, requestPath)
+	pathItem, ok := s.plan.swagger.Paths.Paths[pathName]
+	var op *spec.Operation
+	if ok {
+		op = GetOperationByMethod(&pathItem, strings.ToLower(req.Method))
+	}
+	if op == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	key := operationKey(req.Method, pathName)
+	s.mutex.Lock()
+	var r cannedResponse
+	if canned := s.responses[key]; len(canned) > 0 && !s.mockOnly {
+		r = canned[s.calls[key]%len(canned)]
+	} else {
+		r = s.mockResponse(op)
+	}
+	s.calls[key]++
+	s.mutex.Unlock()
+
+	mqutil.Logger.Printf("selftest: %s %s -> %d", req.Method, req.URL.Path, r.status)
+	if r.body == nil {
+		w.WriteHeader(r.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(r.status)
+	json.NewEncoder(w).Encode(r.body)
+}
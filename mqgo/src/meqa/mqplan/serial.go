@@ -0,0 +1,39 @@
+package mqplan
+
+import (
+	"sync"
+)
+
+// SerialExtension is the swagger extension on operations that must not be called concurrently.
+const SerialExtension = "x-meqa-serial"
+
+// IsSerial returns whether the test's operation must not run concurrently with other calls to the same
+// operation. This is set either on the test in the plan, or on the operation through x-meqa-serial.
+func (t *Test) IsSerial() bool {
+	if t.Serial {
+		return true
+	}
+	if t.op == nil {
+		return false
+	}
+	serial, ok := t.op.Extensions[SerialExtension].(bool)
+	return ok && serial
+}
+
+// LockOperation acquires the lock of the operation, and returns the function that releases it.
+func (plan *TestPlan) LockOperation(method string, path string) func() {
+	key := operationKey(method, path)
+	plan.operationMutex.Lock()
+	if plan.operationLocks == nil {
+		plan.operationLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := plan.operationLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		plan.operationLocks[key] = lock
+	}
+	plan.operationMutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
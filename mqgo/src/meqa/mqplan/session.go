@@ -0,0 +1,101 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// The -redirects values besides the number of redirects to follow.
+const (
+	RedirectsNone     = "none"      // a redirect fails the call
+	RedirectsNoFollow = "no-follow" // the 3xx is the response of the call, checked against the swagger
+)
+
+// DefaultRedirects is how many redirects the calls follow by default.
+const DefaultRedirects = 15
+
+// ParseRedirects returns the redirect policy of a -redirects value: none, no-follow or the most redirects
+// to follow. Empty is the default.
+func ParseRedirects(redirects string) (resty.RedirectPolicy, error) {
+	switch redirects {
+	case "":
+		return resty.FlexibleRedirectPolicy(DefaultRedirects), nil
+	case RedirectsNone:
+		return resty.NoRedirectPolicy(), nil
+	case RedirectsNoFollow:
+		return resty.RedirectPolicyFunc(func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}), nil
+	}
+	count, err := strconv.Atoi(redirects)
+	if err != nil || count < 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"invalid -redirects %s, expecting none, no-follow or the number of redirects to follow", redirects))
+	}
+	return resty.FlexibleRedirectPolicy(count), nil
+}
+
+// RedirectPolicy returns the redirect policy of the plan's Redirects, the default one if it's invalid.
+func (plan *TestPlan) RedirectPolicy() resty.RedirectPolicy {
+	policy, err := ParseRedirects(plan.Redirects)
+	if err != nil {
+		policy, _ = ParseRedirects("")
+	}
+	return policy
+}
+
+// Session is the HTTP state a real client keeps between calls: the resty client with its cookie jar.
+// By default one session is shared by all the test suites of the run.
+type Session struct {
+	Client *resty.Client
+
+	loginErr error // why the login of the session failed, see Login
+}
+
+// NewSession creates a session with an empty cookie jar, configured like the default resty client.
+func (plan *TestPlan) NewSession() *Session {
+	client := resty.New()
+	if plan.MaxResponseBytes > 0 {
+		client.SetTransport(plan.decompressing(plan.limitedTransport(plan.MaxResponseBytes)))
+	} else {
+		client.SetTransport(plan.decompressing(&http.Transport{Proxy: plan.proxy(), TLSClientConfig: plan.TLSConfig}))
+	}
+	client.SetRedirectPolicy(plan.RedirectPolicy())
+	if plan.Timeout > 0 {
+		client.SetTimeout(plan.Timeout)
+	}
+	return &Session{Client: client}
+}
+
+// Session returns the session shared by the suites of the run, creating it on first use.
+func (plan *TestPlan) Session() *Session {
+	plan.mutex.Lock()
+	defer plan.mutex.Unlock()
+	if plan.session == nil {
+		plan.session = plan.NewSession()
+		plan.session.loginErr = plan.login(plan.session)
+	}
+	return plan.session
+}
+
+// R creates a request on the suite's session.
+func (tc *TestSuite) R() *resty.Request {
+	if tc.session == nil {
+		tc.session = tc.plan.Session()
+	}
+	return tc.session.Client.R()
+}
+
+// proxy returns the proxy function of the transports, the proxies in the environment by default.
+func (plan *TestPlan) proxy() func(*http.Request) (*url.URL, error) {
+	if plan.Proxy != nil {
+		return plan.Proxy
+	}
+	return http.ProxyFromEnvironment
+}
@@ -0,0 +1,205 @@
+package mqplan
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// AWSCredentials are used to sign the requests with AWS signature version 4.
+type AWSCredentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// awsURIEncode encodes everything except the unreserved characters, as required by SigV4.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalURI encodes the path. Every service but s3 expects each segment to be encoded twice.
+func canonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = awsURIEncode(s, true)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalRequest returns the canonical request of SigV4 and its signed headers. The header names
+// should be lower case.
+func canonicalRequest(method string, uri string, query url.Values, headers map[string]string, hash string) (string, string) {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders string
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		uri,
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		hash,
+	}, "\n"), signedHeaders
+}
+
+// sigV4Signature signs the canonical request with a key derived from the secret key, and returns the
+// credential scope and the signature.
+func sigV4Signature(secretKey, region, service string, now time.Time, canonicalRequest string) (string, string) {
+	date := now.Format(sigV4DateFormat)
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, now.Format(sigV4TimeFormat), scope,
+		sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// payloadHash hashes the body set on the request, and sets it again as the bytes we hash, so that what's
+// sent is exactly what's signed. The bodies that aren't bytes or a string, which resty would marshal to
+// json when sending, are marshaled here.
+func (t *Test) payloadHash(req *resty.Request) (string, error) {
+	if req.Body != nil {
+		var payload []byte
+		switch body := req.Body.(type) {
+		case []byte:
+			// E.g. the xml bodies.
+			payload = body
+		case string:
+			payload = []byte(body)
+		default:
+			var err error
+			payload, err = json.Marshal(body)
+			if err != nil {
+				return "", err
+			}
+		}
+		req.SetBody(payload)
+		if len(req.Header.Get("Content-Type")) == 0 {
+			req.SetHeader("Content-Type", "application/json")
+		}
+		return sha256Hex(payload), nil
+	}
+	for _, p := range t.op.Parameters {
+		if p.Type == "file" && t.FormParams[p.Name] != nil {
+			// The multipart body is built by resty when sending, we can't hash it beforehand.
+			return sigV4UnsignedPayload, nil
+		}
+	}
+	if len(req.FormData) > 0 {
+		if len(req.Header.Get("Content-Type")) == 0 {
+			req.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+		}
+		return sha256Hex([]byte(req.FormData.Encode())), nil
+	}
+	return sha256Hex(nil), nil
+}
+
+// SignSigV4 signs the request per the AWS signature version 4 spec, and sets the Authorization header.
+func (t *Test) SignSigV4(plan *TestPlan, req *resty.Request, path string, now time.Time) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't sign request to %s: %s", path, err.Error()))
+	}
+	query := u.Query()
+	for k, values := range req.QueryParam {
+		for _, v := range values {
+			query.Add(k, v)
+		}
+	}
+	hash, err := t.payloadHash(req)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't sign request body: %s", err.Error()))
+	}
+
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	req.SetHeader("X-Amz-Date", amzDate)
+	req.SetHeader("X-Amz-Content-Sha256", hash)
+	if len(plan.AWSCredentials.SessionToken) > 0 {
+		req.SetHeader("X-Amz-Security-Token", plan.AWSCredentials.SessionToken)
+	}
+
+	// We sign host, content-type and all the x-amz-* headers.
+	headers := map[string]string{"host": u.Host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	canonical, signedHeaders := canonicalRequest(t.Method, canonicalURI(u, plan.AWSService), query, headers, hash)
+	scope, signature := sigV4Signature(plan.AWSCredentials.SecretKey, plan.AWSRegion, plan.AWSService, now, canonical)
+
+	req.SetHeader("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, plan.AWSCredentials.AccessKey, scope, signedHeaders, signature))
+	mqutil.Logger.Printf("sigv4 canonical request:\n%s", canonical)
+	return nil
+}
@@ -0,0 +1,179 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/resty.v0"
+)
+
+// The credentials, region, service and time of the AWS SigV4 test suite.
+const (
+	suiteAccessKey = "AKIDEXAMPLE"
+	suiteSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	suiteRegion    = "us-east-1"
+	suiteService   = "service"
+)
+
+var suiteTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSigV4Suite(t *testing.T) {
+	unreserved := "-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		headers   map[string]string
+		body      string
+		creq      string
+		signature string
+	}{
+		{"get-vanilla", "GET", "/", nil, "",
+			"GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key", "GET", "/?Param1=value2&Param1=Value1", nil, "",
+			"GET\n/\nParam1=Value1&Param1=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"eedbc4e291e521cf13422ffca22be7d2eb8146eecf653089df300a15b2382bd1"},
+		{"get-vanilla-query-order-key-case", "GET", "/?Param2=value2&Param1=value1", nil, "",
+			"GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-query-order-value", "GET", "/?Param1=value2&Param1=value1", nil, "",
+			"GET\n/\nParam1=value1&Param1=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		{"get-vanilla-query-unreserved", "GET", "/?" + unreserved + "=" + unreserved, nil, "",
+			"GET\n/\n" + unreserved + "=" + unreserved + "\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197"},
+		{"get-unreserved", "GET", "/" + unreserved, nil, "",
+			"GET\n/" + unreserved + "\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"07ef7494c76fa4850883e2b006601f940f8a34d404d0cfa977f52a65bbf5f24f"},
+		{"post-vanilla", "POST", "/", nil, "",
+			"POST\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			"5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-x-www-form-urlencoded", "POST", "/", map[string]string{"content-type": "application/x-www-form-urlencoded"},
+			"Param1=value1",
+			"POST\n/\n\ncontent-type:application/x-www-form-urlencoded\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-date\n9095672bbd1f56dfc5b65f3e153adc8731a4a654192329106275f4c7b24d0b6e",
+			"ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, c := range cases {
+		u, err := url.Parse("https://example.amazonaws.com" + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers := map[string]string{"host": u.Host, "x-amz-date": suiteTime.Format(sigV4TimeFormat)}
+		for k, v := range c.headers {
+			headers[k] = v
+		}
+		creq, _ := canonicalRequest(c.method, canonicalURI(u, suiteService), u.Query(), headers, sha256Hex([]byte(c.body)))
+		if creq != c.creq {
+			t.Errorf("%s: got the canonical request\n%s\nexpecting\n%s", c.name, creq, c.creq)
+		}
+		scope, signature := sigV4Signature(suiteSecretKey, suiteRegion, suiteService, suiteTime, creq)
+		if scope != "20150830/us-east-1/service/aws4_request" || signature != c.signature {
+			t.Errorf("%s: got the scope %s and the signature %s, expecting %s", c.name, scope, signature, c.signature)
+		}
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	cases := []struct {
+		path    string
+		service string
+		uri     string
+	}{
+		{"", "service", "/"},
+		{"/", "service", "/"},
+		{"/pets/1", "service", "/pets/1"},
+		{"/example space/", "execute-api", "/example%2520space/"},
+		{"/example%20space/", "execute-api", "/example%2520space/"},
+		{"/ሴ", "execute-api", "/%25E1%2588%25B4"},
+		{"/a+b(c)", "execute-api", "/a%2Bb%2528c%2529"},
+		{"/example space/", "s3", "/example%20space/"},
+		{"/ሴ", "s3", "/%E1%88%B4"},
+	}
+	for _, c := range cases {
+		u := &url.URL{Scheme: "https", Host: "example.amazonaws.com"}
+		unescaped, err := url.PathUnescape(c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Path = unescaped
+		if uri := canonicalURI(u, c.service); uri != c.uri {
+			t.Errorf("canonicalURI(%s, %s) = %s, expecting %s", c.path, c.service, uri, c.uri)
+		}
+	}
+}
+
+func TestSignSigV4(t *testing.T) {
+	plan := &TestPlan{
+		AWSRegion:      suiteRegion,
+		AWSService:     "execute-api",
+		AWSCredentials: AWSCredentials{AccessKey: suiteAccessKey, SecretKey: suiteSecretKey, SessionToken: "token"},
+	}
+	test := &Test{Method: "post", op: &spec.Operation{}}
+	req := resty.R()
+	req.SetBody(map[string]interface{}{"name": "rex", "tags": []string{"b", "a"}})
+	req.QueryParam.Add("b", "2")
+	req.QueryParam.Add("a", "x y")
+	if err := test.SignSigV4(plan, req, "https://example.amazonaws.com/stores/my store/pets?z=1", suiteTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// The body that is sent is the one that is hashed.
+	sent, ok := req.Body.([]byte)
+	if !ok {
+		t.Fatalf("expecting the body to be set as bytes, got %T", req.Body)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(sent, &decoded); err != nil || decoded["name"] != "rex" {
+		t.Errorf("got the body %s", sent)
+	}
+	hash := sha256Hex(sent)
+	if h := req.Header.Get("X-Amz-Content-Sha256"); h != hash {
+		t.Errorf("got the payload hash %s, expecting %s", h, hash)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got the content type %s", ct)
+	}
+
+	creq := strings.Join([]string{
+		"POST",
+		"/stores/my%2520store/pets",
+		"a=x%20y&b=2&z=1",
+		"content-type:application/json\nhost:example.amazonaws.com\nx-amz-content-sha256:" + hash +
+			"\nx-amz-date:20150830T123600Z\nx-amz-security-token:token\n",
+		"content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token",
+		hash,
+	}, "\n")
+	_, signature := sigV4Signature(suiteSecretKey, suiteRegion, "execute-api", suiteTime, creq)
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/execute-api/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature=" + signature
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("got the authorization\n%s\nexpecting\n%s", auth, expected)
+	}
+
+	// A body that's already bytes, e.g. xml, is signed as it is.
+	req = resty.R()
+	req.SetHeader("Content-Type", "application/xml")
+	req.SetBody([]byte("<pet><name>rex</name></pet>"))
+	if err := test.SignSigV4(plan, req, "https://example.amazonaws.com/pets", suiteTime); err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("X-Amz-Content-Sha256"); h != sha256Hex([]byte("<pet><name>rex</name></pet>")) {
+		t.Errorf("got the payload hash %s for the xml body", h)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("got the content type %s for the xml body", ct)
+	}
+}
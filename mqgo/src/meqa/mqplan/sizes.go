@@ -0,0 +1,61 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/resty.v0"
+)
+
+// OperationSize holds the largest response body of one operation in the run.
+type OperationSize struct {
+	Operation string
+	Test      string
+	Bytes     int
+}
+
+func (o *OperationSize) String() string {
+	return fmt.Sprintf("%s: %d bytes (%s)", o.Operation, o.Bytes, o.Test)
+}
+
+// recordSizes records the byte sizes of the request and response bodies of the test.
+func (t *Test) recordSizes(req *resty.Request, resp *resty.Response) {
+	if req.RawRequest != nil && req.RawRequest.ContentLength > 0 {
+		t.RequestBytes = int(req.RawRequest.ContentLength)
+	} else if t.BodyParams != nil {
+		if payload, err := json.Marshal(t.BodyParams); err == nil {
+			t.RequestBytes = len(payload)
+		}
+	}
+	if resp != nil {
+		t.ResponseBytes = len(resp.Body())
+	}
+}
+
+// LargestResponses returns the largest response body of each operation, largest first.
+func (plan *TestPlan) LargestResponses() []*OperationSize {
+	largest := make(map[string]*OperationSize)
+	for _, test := range plan.resultList {
+		if test.ResponseBytes == 0 {
+			continue
+		}
+		key := strings.ToUpper(test.Method) + " " + test.Path
+		if size, ok := largest[key]; !ok || test.ResponseBytes > size.Bytes {
+			largest[key] = &OperationSize{key, test.Name, test.ResponseBytes}
+		}
+	}
+
+	var sizes []*OperationSize
+	for _, size := range largest {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Bytes != sizes[j].Bytes {
+			return sizes[i].Bytes > sizes[j].Bytes
+		}
+		return sizes[i].Operation < sizes[j].Operation
+	})
+	return sizes
+}
@@ -0,0 +1,205 @@
+package mqplan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// stateGetter is the GET operation that fetches one object of a definition, used to check that the
+// objects of the state file still exist.
+type stateGetter struct {
+	path   string
+	op     *spec.Operation
+	params []spec.Parameter // of both the operation and the path
+}
+
+// stateGetter returns the GET operation with a path parameter that is a property of the definition, e.g.
+// GET /pet/{petId} for Pet. Nil if there is none.
+func (plan *TestPlan) stateGetter(className string) *stateGetter {
+	var paths []string
+	for path := range plan.swagger.Paths.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		pathItem := plan.swagger.Paths.Paths[path]
+		if pathItem.Get == nil {
+			continue
+		}
+		params := ParamsAdd(append([]spec.Parameter(nil), pathItem.Get.Parameters...), pathItem.Parameters)
+		for _, param := range params {
+			if tag := mqswag.GetMeqaTag(param.Description); param.In == "path" && tag != nil && tag.Class == className {
+				return &stateGetter{path, pathItem.Get, params}
+			}
+		}
+	}
+	return nil
+}
+
+// pathParams returns the path parameters of the GET for the object, from its properties and from the
+// path parameters it was created under. False if some are missing.
+func (g *stateGetter) pathParams(className string, entry *mqswag.DBEntry) (map[string]interface{}, bool) {
+	params := make(map[string]interface{})
+	for _, param := range g.params {
+		if param.In != "path" {
+			continue
+		}
+		if tag := mqswag.GetMeqaTag(param.Description); tag != nil && tag.Class == className && entry.Data[tag.Property] != nil {
+			params[param.Name] = entry.Data[tag.Property]
+		} else if entry.PathParams[param.Name] != nil {
+			params[param.Name] = entry.PathParams[param.Name]
+		} else {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// CheckState checks with a GET that the server still has the objects loaded from the state file. The
+// ones it doesn't have, and the ones there is no GET operation for, are dropped from the DB, so that the
+// tests don't use them. Returns the number of objects kept and dropped.
+func (plan *TestPlan) CheckState() (int, int) {
+	kept, dropped := 0, 0
+	tc := CreateTestSuite("verify_state", nil, plan)
+	tc.session = plan.Session()
+	for _, className := range plan.db.StateNames() {
+		getter := plan.stateGetter(className)
+		if getter == nil {
+			mqutil.Warnf("dropping the saved %s objects, there is no GET operation to check them with", className)
+		}
+		for _, entry := range plan.db.StateObjects(className) {
+			if getter != nil && plan.stateExists(tc, getter, className, entry) {
+				kept++
+				continue
+			}
+			plan.db.DropState(className, entry)
+			dropped++
+		}
+	}
+	return kept, dropped
+}
+
+// stateExists returns whether the GET of the object succeeds. Only a 404 or 410 means that the object is
+// gone, the other failures keep it.
+func (plan *TestPlan) stateExists(tc *TestSuite, getter *stateGetter, className string, entry *mqswag.DBEntry) bool {
+	params, ok := getter.pathParams(className, entry)
+	if !ok {
+		mqutil.Warnf("dropping a saved %s object, it lacks the path parameters of GET %s", className, getter.path)
+		return false
+	}
+	t := &Test{Name: "verify_state", Path: getter.path, Method: mqswag.MethodGet}
+	t.PathParams = params
+	t.Init(tc)
+	t.op = getter.op
+
+	req := tc.R()
+	if _, err := t.setCredentials(tc, req); err != nil {
+		mqutil.Warnf("can't check the saved %s object: %s", className, err.Error())
+		return true
+	}
+	t.SetHeaders(plan, req)
+	baseURL := plan.BaseURL
+	if len(baseURL) == 0 {
+		baseURL = GetBaseURL(plan.swagger)
+	}
+	path := baseURL + t.SetRequestParameters(req)
+	if plan.SigV4 {
+		if err := t.SignSigV4(plan, req, path, time.Now()); err != nil {
+			mqutil.Warnf("can't check the saved %s object: %s", className, err.Error())
+			return true
+		}
+	}
+	if plan.RateLimiter != nil {
+		plan.RateLimiter.Wait()
+	}
+	resp, err := t.call(req, path)
+	if err != nil {
+		mqutil.Warnf("can't check the saved %s object at %s: %s", className, path, err.Error())
+		return true
+	}
+	status := resp.StatusCode()
+	if status == 404 || status == 410 {
+		mqutil.Logger.Printf("the saved %s object at %s is gone (%d), dropping it", className, path, status)
+		return false
+	}
+	if status >= 300 {
+		mqutil.Warnf("checking the saved %s object at %s got %d, keeping it", className, path, status)
+	}
+	return true
+}
+
+// The note on the tests that create an object, skipped with PreferExisting as the state file has one.
+const NoteExisting = "existing"
+
+// createdClass returns the definition of the objects the test's operation creates, "" if it isn't a create.
+func (t *Test) createdClass(swagger *mqswag.Swagger) string {
+	pathItem, ok := swagger.Paths.Paths[t.Path]
+	if !ok {
+		return ""
+	}
+	method := strings.ToLower(t.Method)
+	op := GetOperationByMethod(&pathItem, method)
+	if op == nil {
+		return ""
+	}
+	// Like the DAG, the operation of the tag wins over the method.
+	tag := mqswag.GetMeqaTag(op.Description)
+	if tag != nil && len(tag.Operation) > 0 {
+		if tag.Operation != mqswag.MethodPost {
+			return ""
+		}
+	} else if method != mqswag.MethodPost {
+		return ""
+	}
+	if tag != nil && len(tag.Class) > 0 {
+		return tag.Class
+	}
+	for _, param := range op.Parameters {
+		if param.In != "body" || param.Schema == nil {
+			continue
+		}
+		paramTag, schema := swagger.GetSchemaRootType((*mqswag.Schema)(param.Schema), mqswag.GetMeqaTag(param.Description))
+		if schema != nil && paramTag != nil {
+			return paramTag.Class
+		}
+	}
+	return ""
+}
+
+// existingObject returns the object loaded from the state file that the test would create another one
+// of, with PreferExisting, and its definition. Nil when the test has to run.
+func (plan *TestPlan) existingObject(test *Test) (*mqswag.DBEntry, string) {
+	if !plan.PreferExisting || plan.swagger == nil || plan.db == nil || len(test.Data) != 0 {
+		return nil, ""
+	}
+	className := test.createdClass(plan.swagger)
+	if len(className) == 0 {
+		return nil, ""
+	}
+	if objects := plan.db.StateObjects(className); len(objects) > 0 {
+		return objects[0], className
+	}
+	return nil, ""
+}
+
+// useExisting records the create test as skipped in favor of the loaded object. The object is its output,
+// so that the later tests that take a value from the test's outputs get the object's.
+func (plan *TestPlan) useExisting(tc *TestSuite, test *Test, className string, existing *mqswag.DBEntry) {
+	mqutil.Logger.Printf("skipping %s, using a %s object of the state file", test.Name, className)
+	fmt.Printf("\nSkipping test case: %s (using an existing %s)\n", test.Name, className)
+	skipped := test.Duplicate()
+	skipped.Notes = []string{NoteExisting}
+	skipped.Expect = map[string]interface{}{ExpectBody: mqutil.MapCopy(existing.Data)}
+	History.Append(skipped)
+	plan.addResult(tc, skipped)
+	plan.mutex.Lock()
+	plan.SkippedExisting++
+	plan.mutex.Unlock()
+}
@@ -0,0 +1,51 @@
+package mqplan
+
+import (
+	"time"
+)
+
+// RunSummary is the outcome of the last run, as printed by "mqgo run -json".
+type RunSummary struct {
+	Total       int      `json:"total"`
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	Skipped     int      `json:"skipped"`
+	Duration    float64  `json:"durationSeconds"`
+	BaseURL     string   `json:"baseUrl"`
+	FailedTests []string `json:"failedTests"` // as suite/test
+	ExitCode    int      `json:"exitCode"`
+}
+
+// baseURL returns the url the tests call, the BaseURL or the one of the swagger.
+func (plan *TestPlan) baseURL() string {
+	if len(plan.BaseURL) == 0 && plan.swagger != nil {
+		return GetBaseURL(plan.swagger)
+	}
+	return plan.BaseURL
+}
+
+// Summary returns the counts and the failed tests of the last run, which took the duration.
+func (plan *TestPlan) Summary(duration time.Duration) *RunSummary {
+	summary := &RunSummary{
+		Total:       len(plan.resultList),
+		Duration:    duration.Seconds(),
+		BaseURL:     plan.baseURL(),
+		FailedTests: []string{},
+	}
+	for _, test := range plan.resultList {
+		switch {
+		case test.err != nil:
+			summary.Failed++
+			name := test.Name
+			if test.root != nil {
+				name = test.root.Name + "/" + test.Name
+			}
+			summary.FailedTests = append(summary.FailedTests, name)
+		case test.skipped():
+			summary.Skipped++
+		default:
+			summary.Passed++
+		}
+	}
+	return summary
+}
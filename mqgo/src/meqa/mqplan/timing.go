@@ -0,0 +1,64 @@
+package mqplan
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OperationTiming holds the latency percentiles of one operation across all the calls in the run.
+type OperationTiming struct {
+	Operation string
+	Count     int
+	P50       time.Duration
+	P90       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+}
+
+func (o *OperationTiming) String() string {
+	return fmt.Sprintf("%s: count %d, p50 %v, p90 %v, p95 %v, p99 %v", o.Operation, o.Count, o.P50, o.P90, o.P95, o.P99)
+}
+
+// percentile uses the nearest rank method on the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// OperationTimings aggregates the call durations in the run by operation.
+func (plan *TestPlan) OperationTimings() []*OperationTiming {
+	durations := make(map[string][]time.Duration)
+	for _, test := range plan.resultList {
+		if test.startTime.IsZero() || test.stopTime.IsZero() {
+			continue
+		}
+		key := strings.ToUpper(test.Method) + " " + test.Path
+		durations[key] = append(durations[key], test.stopTime.Sub(test.startTime))
+	}
+
+	var timings []*OperationTiming
+	for key, list := range durations {
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		timings = append(timings, &OperationTiming{key, len(list),
+			percentile(list, 50), percentile(list, 90), percentile(list, 95), percentile(list, 99)})
+	}
+	sort.Slice(timings, func(i, j int) bool { return timings[i].Operation < timings[j].Operation })
+	return timings
+}
+
+// SlowOperations returns the operations whose p95 latency exceeds the plan's P95Under.
+func (plan *TestPlan) SlowOperations() []*OperationTiming {
+	var slow []*OperationTiming
+	for _, timing := range plan.OperationTimings() {
+		if timing.P95 > plan.P95Under {
+			slow = append(slow, timing)
+		}
+	}
+	return slow
+}
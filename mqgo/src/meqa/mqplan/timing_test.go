@@ -0,0 +1,81 @@
+package mqplan
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func durations(ms ...int) []time.Duration {
+	var list []time.Duration
+	for _, m := range ms {
+		list = append(list, time.Duration(m)*time.Millisecond)
+	}
+	return list
+}
+
+func TestPercentile(t *testing.T) {
+	ten := durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = time.Duration(i+1) * time.Millisecond
+	}
+	cases := []struct {
+		sorted []time.Duration
+		p      float64
+		result int
+	}{
+		{durations(7), 50, 7},
+		{durations(7), 99, 7},
+		{durations(3, 9), 50, 3},
+		{durations(3, 9), 51, 9},
+		{durations(1, 2, 3), 50, 2},
+		{durations(1, 2, 3), 90, 3},
+		{ten, 0, 1},
+		{ten, 10, 1},
+		{ten, 11, 2},
+		{ten, 50, 5},
+		{ten, 90, 9},
+		{ten, 95, 10},
+		{ten, 99, 10},
+		{ten, 100, 10},
+		{hundred, 50, 50},
+		{hundred, 95, 95},
+		{hundred, 99, 99},
+	}
+	for _, c := range cases {
+		expected := time.Duration(c.result) * time.Millisecond
+		if result := percentile(c.sorted, c.p); result != expected {
+			t.Errorf("percentile(%v, %v) = %v, expecting %v", c.sorted, c.p, result, expected)
+		}
+	}
+}
+
+func TestOperationTimings(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	call := func(method, path string, ms int) *Test {
+		test := &Test{Method: method, Path: path}
+		test.startTime = start
+		test.stopTime = start.Add(time.Duration(ms) * time.Millisecond)
+		return test
+	}
+	plan := &TestPlan{P95Under: 20 * time.Millisecond}
+	plan.resultList = []*Test{
+		call("post", "/pets", 30),
+		call("get", "/pets", 10),
+		call("get", "/pets", 2),
+		call("GET", "/pets", 6),
+		{Method: "delete", Path: "/pets"},
+	}
+
+	expected := []*OperationTiming{
+		{"GET /pets", 3, 6 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+		{"POST /pets", 1, 30 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond},
+	}
+	if timings := plan.OperationTimings(); !reflect.DeepEqual(timings, expected) {
+		t.Errorf("got the timings %v, expecting %v", timings, expected)
+	}
+	if slow := plan.SlowOperations(); !reflect.DeepEqual(slow, expected[1:]) {
+		t.Errorf("got the slow operations %v, expecting %v", slow, expected[1:])
+	}
+}
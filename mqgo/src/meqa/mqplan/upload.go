@@ -0,0 +1,63 @@
+package mqplan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqutil"
+)
+
+// The fixture recorded for the file parameters that were sent with generated content.
+const FixtureGenerated = "generated"
+
+// fileUpload is the generated content of a file parameter that has no fixture.
+type fileUpload struct {
+	fileName string
+	content  []byte
+}
+
+// fixture returns the file of the plan's fixtures directory for the parameter, the one named after the
+// parameter, with or without an extension. Empty if there is none.
+func (plan *TestPlan) fixture(name string) string {
+	if len(plan.FixturesDir) == 0 {
+		return ""
+	}
+	candidates := []string{filepath.Join(plan.FixturesDir, name)}
+	matches, _ := filepath.Glob(filepath.Join(plan.FixturesDir, name+".*"))
+	for _, path := range append(candidates, matches...) {
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// generateFile returns the file to upload for the file parameter, the fixture if there is one. Otherwise a
+// small text file is generated, the same for every run so that the failures are reproducible.
+func (t *Test) generateFile(paramSpec *spec.Parameter) (interface{}, error) {
+	if path := t.suite.plan.fixture(paramSpec.Name); len(path) > 0 {
+		mqutil.Debugf("uploading the fixture %s for the %s parameter", path, paramSpec.Name)
+		return path, nil
+	}
+	mqutil.Debugf("uploading a generated file for the %s parameter", paramSpec.Name)
+	upload := &fileUpload{
+		fileName: "meqa-" + paramSpec.Name + ".txt",
+		content:  []byte(fmt.Sprintf("meqa test file for the %s parameter of %s %s\n", paramSpec.Name, t.Method, t.Path)),
+	}
+	if t.files == nil {
+		t.files = make(map[string]*fileUpload)
+	}
+	t.files[paramSpec.Name] = upload
+	return upload.fileName, nil
+}
+
+// setFixture records the file sent for the file parameter.
+func (t *Test) setFixture(name string, fixture string) {
+	if t.Fixtures == nil {
+		t.Fixtures = make(map[string]string)
+	}
+	t.Fixtures[name] = fixture
+}
@@ -0,0 +1,91 @@
+package mqplan
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/go-openapi/spec"
+	"github.com/xeipuuv/gojsonschema"
+
+	"meqa/mqswag"
+	"meqa/mqutil"
+)
+
+// generateEnumArray returns distinct enum values in random order, as many as the enum has, up to maxItems.
+func (t *Test) generateEnumArray(schema *spec.Schema, itemSchema *spec.Schema, level int) []interface{} {
+	num := len(itemSchema.Enum)
+	if schema.MaxItems != nil && int(*schema.MaxItems) < num && *schema.MaxItems > 0 {
+		num = int(*schema.MaxItems)
+	}
+	if level != 0 {
+		fmt.Print("enum\n")
+	}
+	integer := len(itemSchema.Type) > 0 && itemSchema.Type[0] == gojsonschema.TYPE_INTEGER
+	var ar []interface{}
+	for _, i := range rand.Perm(len(itemSchema.Enum))[:num] {
+		v, _ := generateEnum(itemSchema.Enum[i:i+1], integer)
+		ar = append(ar, v)
+	}
+	return ar
+}
+
+// arrayKeyField picks the field that tells the objects apart: id if there is one, otherwise the first
+// required primitive field.
+func arrayKeyField(schema *mqswag.Schema) string {
+	if p, ok := schema.Properties["id"]; ok && !p.Type.Contains(gojsonschema.TYPE_OBJECT) && !p.Type.Contains(gojsonschema.TYPE_ARRAY) {
+		return "id"
+	}
+	required := append([]string{}, schema.Required...)
+	sort.Strings(required)
+	for _, name := range required {
+		p, ok := schema.Properties[name]
+		if ok && len(p.Type) > 0 && !p.Type.Contains(gojsonschema.TYPE_OBJECT) && !p.Type.Contains(gojsonschema.TYPE_ARRAY) {
+			return name
+		}
+	}
+	return ""
+}
+
+// varyKeyField makes the key field differ across the object entries of an array. Enum fields go round
+// robin through the enum values, the others are generated again until they are distinct.
+func (t *Test) varyKeyField(ar []interface{}, itemSchema *spec.Schema, db *mqswag.DB) error {
+	schema := (*mqswag.Schema)(itemSchema)
+	_, referred, err := t.db.Swagger.GetReferredSchema(schema)
+	if err != nil {
+		return err
+	}
+	if referred != nil {
+		schema = referred
+	}
+	key := arrayKeyField(schema)
+	if len(key) == 0 {
+		return nil
+	}
+	property := schema.Properties[key]
+	integer := len(property.Type) > 0 && property.Type[0] == gojsonschema.TYPE_INTEGER
+
+	seen := make(map[string]bool)
+	for i, entry := range ar {
+		obj, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if len(property.Enum) > 0 {
+			obj[key], _ = generateEnum(property.Enum[i%len(property.Enum):i%len(property.Enum)+1], integer)
+			continue
+		}
+		for tries := 0; seen[fmt.Sprint(obj[key])] && tries < 10; tries++ {
+			v, err := t.GenerateSchema(key+"_", nil, &property, db, 0)
+			if err != nil {
+				return err
+			}
+			obj[key] = v
+		}
+		if seen[fmt.Sprint(obj[key])] {
+			mqutil.Logger.Printf("can't generate distinct values for %s", key)
+		}
+		seen[fmt.Sprint(obj[key])] = true
+	}
+	return nil
+}
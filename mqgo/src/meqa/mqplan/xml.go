@@ -0,0 +1,338 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+)
+
+// isXML returns whether the media type is xml, e.g. application/xml, text/xml or application/soap+xml.
+func isXML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// produces returns the media types the operation responds with, falling back to the swagger's.
+func produces(swagger *mqswag.Swagger, op *spec.Operation) []string {
+	if op != nil && len(op.Produces) > 0 {
+		return op.Produces
+	}
+	return swagger.Produces
+}
+
+// accept returns the Accept header of the test's request. Xml is asked for when the operation only
+// responds with xml, or when the request body is xml and the operation can respond with it. Empty
+// otherwise, the servers send json by default.
+func (t *Test) accept() string {
+	types := produces(t.db.Swagger, t.op)
+	var xmlType string
+	for _, mediaType := range types {
+		if isJSON(mediaType) && !isXML(t.ContentType) {
+			return ""
+		}
+		if isXML(mediaType) && len(xmlType) == 0 {
+			xmlType = mediaType
+		}
+	}
+	if isXML(t.ContentType) {
+		for _, mediaType := range types {
+			if mediaType == t.ContentType {
+				return mediaType
+			}
+		}
+	}
+	return xmlType
+}
+
+// DecodeXML is the handler for xml types. The root element is the object, the nested elements and the
+// attributes are its fields, and the repeated elements are arrays. The values are all strings, until
+// fromXML converts them with the response schema.
+func DecodeXML(body []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return decodeElement(d, start)
+		}
+	}
+}
+
+func decodeElement(d *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		fields[attr.Name.Local] = attr.Value
+	}
+	var text bytes.Buffer
+	hasChildren := false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("unexpected end of xml in element %s", start.Name.Local)
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			hasChildren = true
+			child, err := decodeElement(d, tok)
+			if err != nil {
+				return nil, err
+			}
+			name := tok.Name.Local
+			switch existing := fields[name].(type) {
+			case nil:
+				fields[name] = child
+			case []interface{}:
+				fields[name] = append(existing, child)
+			default:
+				fields[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			if !hasChildren && len(start.Attr) == 0 {
+				return text.String(), nil
+			}
+			return fields, nil
+		}
+	}
+}
+
+// xmlSchema follows the $refs of the schema, and returns the name of the definition it refers to.
+func xmlSchema(schema *mqswag.Schema, swagger *mqswag.Swagger) (string, *mqswag.Schema) {
+	name := ""
+	for i := 0; schema != nil && i < 10; i++ {
+		referredName, referred, err := swagger.GetReferredSchema(schema)
+		if err != nil || referred == nil {
+			break
+		}
+		name, schema = referredName, referred
+	}
+	return name, schema
+}
+
+// itemsSchema returns the schema of the items of an array schema.
+func itemsSchema(schema *mqswag.Schema) *mqswag.Schema {
+	if schema.Items == nil || schema.Items.Schema == nil {
+		return nil
+	}
+	return (*mqswag.Schema)(schema.Items.Schema)
+}
+
+// xmlName returns the element name of the schema, its xml name or the given one.
+func xmlName(schema *mqswag.Schema, name string) string {
+	if schema != nil && schema.XML != nil && len(schema.XML.Name) > 0 {
+		return schema.XML.Name
+	}
+	return name
+}
+
+// asList returns the value as a list, a single element is a list of one.
+func asList(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return list
+	}
+	return []interface{}{v}
+}
+
+// fromXML converts the object decoded by DecodeXML to the types of the schema, so that the objects created
+// by xml operations are the same as the ones created by json ones: numbers and booleans are converted from
+// their text, and the fields that are arrays in the schema are always lists.
+func fromXML(v interface{}, schema *mqswag.Schema, swagger *mqswag.Swagger) interface{} {
+	_, schema = xmlSchema(schema, swagger)
+	if schema == nil || v == nil {
+		return v
+	}
+	switch {
+	case schema.Type.Contains("array"):
+		// The root or wrapper element holds the items, under their element name.
+		var list []interface{}
+		if wrapper, ok := v.(map[string]interface{}); ok {
+			for _, items := range wrapper {
+				list = asList(items)
+			}
+		}
+		return fromXMLList(list, itemsSchema(schema), swagger)
+	case schema.Type.Contains("object") || len(schema.Properties) > 0:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		obj := make(map[string]interface{})
+		for k, field := range fields {
+			obj[k] = field
+		}
+		for name := range schema.Properties {
+			property := propertySchema(schema, name)
+			_, resolved := xmlSchema(property, swagger)
+			elementName := xmlName(property, name)
+			if resolved != nil && resolved.Type.Contains("array") && !(property.XML != nil && property.XML.Wrapped) {
+				// The unwrapped items are repeated elements named after the items.
+				itemName := xmlName(itemsSchema(resolved), elementName)
+				if value, found := fields[itemName]; found {
+					delete(obj, itemName)
+					obj[name] = fromXMLList(asList(value), itemsSchema(resolved), swagger)
+				}
+				continue
+			}
+			if value, found := fields[elementName]; found {
+				delete(obj, elementName)
+				obj[name] = fromXML(value, property, swagger)
+			}
+		}
+		return obj
+	}
+	text, ok := v.(string)
+	if !ok {
+		return v
+	}
+	text = strings.TrimSpace(text)
+	switch {
+	case schema.Type.Contains("integer") || schema.Type.Contains("number"):
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+	case schema.Type.Contains("boolean"):
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	}
+	return text
+}
+
+func fromXMLList(items []interface{}, schema *mqswag.Schema, swagger *mqswag.Swagger) []interface{} {
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		list = append(list, fromXML(item, schema, swagger))
+	}
+	return list
+}
+
+// encodeXML marshals the body to xml by the schema: the root element is named after the definition, and
+// the properties are elements, or attributes when their xml object says so.
+func encodeXML(body interface{}, schema *mqswag.Schema, name string, swagger *mqswag.Swagger) []byte {
+	var buf bytes.Buffer
+	definition, resolved := xmlSchema(schema, swagger)
+	if len(definition) > 0 {
+		name = definition
+	}
+	writeXML(&buf, xmlName(resolved, xmlName(schema, name)), body, resolved, swagger)
+	return buf.Bytes()
+}
+
+func writeXML(buf *bytes.Buffer, name string, v interface{}, schema *mqswag.Schema, swagger *mqswag.Swagger) {
+	switch value := v.(type) {
+	case nil:
+		return
+	case []interface{}:
+		var items *mqswag.Schema
+		if schema != nil {
+			items = itemsSchema(schema)
+		}
+		_, resolvedItems := xmlSchema(items, swagger)
+		itemName := xmlName(resolvedItems, xmlName(items, name))
+		buf.WriteString("<" + name + ">")
+		for _, item := range value {
+			writeXML(buf, itemName, item, resolvedItems, swagger)
+		}
+		buf.WriteString("</" + name + ">")
+	case map[string]interface{}:
+		var keys []string
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<" + name)
+		var children []string
+		for _, k := range keys {
+			property := propertySchema(schema, k)
+			if property != nil && property.XML != nil && property.XML.Attribute {
+				buf.WriteString(" " + xmlName(property, k) + `="`)
+				xml.EscapeText(buf, []byte(fmt.Sprint(value[k])))
+				buf.WriteString(`"`)
+			} else {
+				children = append(children, k)
+			}
+		}
+		buf.WriteString(">")
+		for _, k := range children {
+			property := propertySchema(schema, k)
+			_, resolved := xmlSchema(property, swagger)
+			elementName := xmlName(property, k)
+			list, isList := value[k].([]interface{})
+			if isList && !(property != nil && property.XML != nil && property.XML.Wrapped) {
+				// Unwrapped arrays repeat the item element in place.
+				var items *mqswag.Schema
+				if resolved != nil {
+					items = itemsSchema(resolved)
+				}
+				_, resolvedItems := xmlSchema(items, swagger)
+				for _, item := range list {
+					writeXML(buf, xmlName(resolvedItems, xmlName(items, elementName)), item, resolvedItems, swagger)
+				}
+				continue
+			}
+			writeXML(buf, elementName, value[k], resolved, swagger)
+		}
+		buf.WriteString("</" + name + ">")
+	default:
+		buf.WriteString("<" + name + ">")
+		xml.EscapeText(buf, []byte(fmt.Sprint(value)))
+		buf.WriteString("</" + name + ">")
+	}
+}
+
+// propertySchema returns the schema of the property of an object schema, nil if it has none.
+func propertySchema(schema *mqswag.Schema, name string) *mqswag.Schema {
+	if schema == nil {
+		return nil
+	}
+	property, ok := schema.Properties[name]
+	if !ok {
+		return nil
+	}
+	return (*mqswag.Schema)(&property)
+}
+
+// xmlBody returns the test's body marshaled to xml by the schema of the operation's body parameter.
+func (t *Test) xmlBody() []byte {
+	for _, param := range t.op.Parameters {
+		if param.In == "body" {
+			return encodeXML(t.BodyParams, (*mqswag.Schema)(param.Schema), param.Name, t.db.Swagger)
+		}
+	}
+	return encodeXML(t.BodyParams, nil, "body", t.db.Swagger)
+}
+
+func init() {
+	RegisterMimeHandler(`^(application|text)/xml$`, DecodeXML)
+	RegisterMimeHandler(`^application/[^/]+\+xml$`, DecodeXML)
+}
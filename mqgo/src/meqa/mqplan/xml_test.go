@@ -0,0 +1,146 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqswag"
+)
+
+func typed(t string) spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{t}}}
+}
+
+func arrayOf(items spec.Schema, itemName string, wrapped bool) spec.Schema {
+	items.XML = &spec.XMLObject{Name: itemName}
+	array := typed("array")
+	array.Items = &spec.SchemaOrArray{Schema: &items}
+	if wrapped {
+		array.XML = &spec.XMLObject{Wrapped: true}
+	}
+	return array
+}
+
+// xmlSwagger has a Pet with an attribute, numbers, a boolean, a wrapped and an unwrapped array, and a
+// nested Category.
+func xmlSwagger() *mqswag.Swagger {
+	id := typed("integer")
+	id.XML = &spec.XMLObject{Attribute: true}
+	category := typed("object")
+	category.Properties = map[string]spec.Schema{"id": typed("integer"), "name": typed("string")}
+	pet := typed("object")
+	pet.Required = []string{"name"}
+	pet.Properties = map[string]spec.Schema{
+		"id":        id,
+		"name":      typed("string"),
+		"price":     typed("number"),
+		"available": typed("boolean"),
+		"tags":      arrayOf(typed("string"), "tag", false),
+		"photoUrls": arrayOf(typed("string"), "photoUrl", true),
+		"category":  {SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Category")}},
+	}
+	return &mqswag.Swagger{SwaggerProps: spec.SwaggerProps{
+		Definitions: spec.Definitions{"Pet": pet, "Category": category},
+	}}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	swagger := xmlSwagger()
+	schema := &mqswag.Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Pet")}}
+	cases := []struct {
+		name string
+		json string
+		xml  string
+	}{
+		{"all the fields",
+			`{"id": 7, "name": "rex", "price": 9.5, "available": true, "tags": ["a", "b"], "photoUrls": ["u1", "u2"],
+			  "category": {"id": 3, "name": "dog"}}`,
+			`<Pet id="7"><available>true</available><category><id>3</id><name>dog</name></category><name>rex</name>` +
+				`<photoUrls><photoUrl>u1</photoUrl><photoUrl>u2</photoUrl></photoUrls><price>9.5</price><tag>a</tag><tag>b</tag></Pet>`},
+		{"arrays of one", `{"name": "rex", "price": 10, "tags": ["a"], "photoUrls": ["u1"]}`,
+			`<Pet><name>rex</name><photoUrls><photoUrl>u1</photoUrl></photoUrls><price>10</price><tag>a</tag></Pet>`},
+		{"escaped text", `{"id": 1, "name": "<rex & \"max\">", "available": false}`,
+			`<Pet id="1"><available>false</available><name>&lt;rex &amp; &#34;max&#34;&gt;</name></Pet>`},
+	}
+	for _, c := range cases {
+		// The objects created by json operations are decoded with numbers.
+		d := json.NewDecoder(bytes.NewReader([]byte(c.json)))
+		d.UseNumber()
+		var obj interface{}
+		if err := d.Decode(&obj); err != nil {
+			t.Fatal(err)
+		}
+
+		encoded := encodeXML(obj, schema, "body", swagger)
+		if string(encoded) != c.xml {
+			t.Errorf("%s: got the xml\n%s\nexpecting\n%s", c.name, encoded, c.xml)
+		}
+		decoded, err := DecodeXML(encoded)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+			continue
+		}
+		result := fromXML(decoded, schema, swagger)
+		if !reflect.DeepEqual(result, obj) {
+			t.Errorf("%s: got %v, expecting %v", c.name, result, obj)
+		}
+		if violations := schema.Violations("$", result, swagger); len(violations) > 0 {
+			t.Errorf("%s: the decoded object doesn't match the schema: %v", c.name, violations)
+		}
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	swagger := xmlSwagger()
+	schema := &mqswag.Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Pet")}}
+	cases := []struct {
+		name   string
+		xml    string
+		result string
+	}{
+		{"declaration and whitespace",
+			"<?xml version=\"1.0\"?>\n<Pet id=\" 7 \">\n  <name>rex</name>\n  <price> 9.5 </price>\n</Pet>\n",
+			`{"id": 7, "name": "rex", "price": 9.5}`},
+		{"unknown elements are kept", `<Pet><name>rex</name><color>brown</color></Pet>`,
+			`{"name": "rex", "color": "brown"}`},
+		{"text that isn't a number is kept", `<Pet><name>1</name><price>cheap</price><available>yes</available></Pet>`,
+			`{"name": "1", "price": "cheap", "available": "yes"}`},
+	}
+	for _, c := range cases {
+		decoded, err := DecodeXML([]byte(c.xml))
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+			continue
+		}
+		d := json.NewDecoder(bytes.NewReader([]byte(c.result)))
+		d.UseNumber()
+		var expected interface{}
+		if err = d.Decode(&expected); err != nil {
+			t.Fatal(err)
+		}
+		if result := fromXML(decoded, schema, swagger); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: got %v, expecting %v", c.name, result, expected)
+		}
+	}
+
+	list := &mqswag.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{"array"},
+		Items: &spec.SchemaOrArray{Schema: &spec.Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Pet")}}}}}
+	decoded, err := DecodeXML([]byte(`<pets><Pet><name>a</name></Pet><Pet><name>b</name></Pet></pets>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}}
+	if result := fromXML(decoded, list, swagger); !reflect.DeepEqual(result, expected) {
+		t.Errorf("got the list %v, expecting %v", result, expected)
+	}
+
+	for _, bad := range []string{"", "<Pet><name>rex</name>", "<Pet></name></Pet>"} {
+		if _, err := DecodeXML([]byte(bad)); err == nil {
+			t.Errorf("expecting an error for %q", bad)
+		}
+	}
+}
@@ -72,7 +72,7 @@ func (node *DAGNode) CheckChildrenWeight() bool {
 		if c.Weight <= node.Weight {
 			return false
 		}
-		if mqutil.Verbose {
+		if mqutil.IsDebug() {
 			fmt.Printf("       -  %s, weight: %d priority: %d\n", c.Name, c.Weight, c.Priority)
 		}
 	}
@@ -223,7 +223,7 @@ func (dag *DAG) Sort() {
 
 func (dag *DAG) CheckWeight() {
 	checkChildren := func(previous *DAGNode, current *DAGNode) error {
-		if mqutil.Verbose {
+		if mqutil.IsDebug() {
 			fmt.Printf("\nname: %s weight: %d priority: %d, children: \n", current.Name, current.Weight, current.Priority)
 		}
 		ok := current.CheckChildrenWeight()
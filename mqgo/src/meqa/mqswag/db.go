@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"meqa/mqutil"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/go-openapi/spec"
@@ -17,6 +21,11 @@ import (
 // Schema is the swagger spec schema.
 type Schema spec.Schema
 
+// isIntegerOnly returns whether the schema takes integers but not other numbers.
+func (schema *Schema) isIntegerOnly() bool {
+	return schema.Type.Contains(gojsonschema.TYPE_INTEGER) && !schema.Type.Contains(gojsonschema.TYPE_NUMBER)
+}
+
 func CreateSchemaFromSimple(s *spec.SimpleSchema, v *spec.CommonValidations) *Schema {
 	schema := spec.Schema{}
 	schema.AddType(s.Type, s.Format)
@@ -149,11 +158,19 @@ func (schema *Schema) Parses(name string, object interface{}, collection map[str
 		if !schema.Type.Contains(gojsonschema.TYPE_INTEGER) && !schema.Type.Contains(gojsonschema.TYPE_NUMBER) {
 			return raiseError("schema is not a floating point number")
 		}
+		if f := reflect.ValueOf(object).Float(); schema.isIntegerOnly() && f != math.Trunc(f) {
+			return raiseError("float value for an integer field")
+		}
 	} else if k == reflect.String {
-		bothAreNumbers := reflect.TypeOf(object).String() == "json.Number" && (schema.Type.Contains(gojsonschema.TYPE_INTEGER) || schema.Type.Contains(gojsonschema.TYPE_NUMBER))
+		number, isNumber := object.(json.Number)
+		bothAreNumbers := isNumber && (schema.Type.Contains(gojsonschema.TYPE_INTEGER) || schema.Type.Contains(gojsonschema.TYPE_NUMBER))
 		if !schema.Type.Contains(gojsonschema.TYPE_STRING) && !bothAreNumbers {
 			return raiseError("schema is not a number")
 		}
+		// We decode the responses with UseNumber, so we still have the literal. 1.0 is not an integer.
+		if bothAreNumbers && schema.isIntegerOnly() && strings.ContainsAny(number.String(), ".eE") {
+			return raiseError("float value for an integer field")
+		}
 	} else if k == reflect.Map {
 		isProperty = false
 		objMap, objIsMap := object.(map[string]interface{})
@@ -227,6 +244,180 @@ func (schema *Schema) Matches(object interface{}, swagger *Swagger) bool {
 	return err == nil
 }
 
+// enumContains checks whether the value is one of the enum values. Numbers are compared by value, since
+// the response numbers are json.Number while the ones from the spec are float64.
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if numberEquals(e, value) || mqutil.InterfaceEquals(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// numberEquals returns true if both are numbers of the same value.
+func numberEquals(a interface{}, b interface{}) bool {
+	af, errA := strconv.ParseFloat(fmt.Sprint(a), 64)
+	bf, errB := strconv.ParseFloat(fmt.Sprint(b), 64)
+	_, aIsString := a.(string)
+	_, bIsString := b.(string)
+	return errA == nil && errB == nil && !aIsString && !bIsString && af == bf
+}
+
+// ValidateEnums checks that the fields of the object that have an enum declared in the schema only
+// hold the allowed values. The path is used in the error message to identify the offending field.
+func (schema *Schema) ValidateEnums(path string, object interface{}, swagger *Swagger) error {
+	if object == nil {
+		return nil
+	}
+	_, referredSchema, err := swagger.GetReferredSchema(schema)
+	if err != nil {
+		return err
+	}
+	if referredSchema != nil {
+		return referredSchema.ValidateEnums(path, object, swagger)
+	}
+	if len(schema.AllOf) > 0 {
+		for _, s := range schema.AllOf {
+			err = ((*Schema)(&s)).ValidateEnums(path, object, swagger)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, object) {
+		return mqutil.NewError(mqutil.ErrServerResp, fmt.Sprintf("%s has value %v, which is not one of the allowed values %v",
+			path, object, schema.Enum))
+	}
+
+	switch o := object.(type) {
+	case map[string]interface{}:
+		properties := schema.GetProperties(swagger)
+		for k, v := range o {
+			if propertySchema, ok := properties[k]; ok {
+				err = ((*Schema)(&propertySchema)).ValidateEnums(path+"."+k, v, swagger)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil {
+			return nil
+		}
+		itemsSchema := (*Schema)(schema.Items.Schema)
+		if itemsSchema == nil && len(schema.Items.Schemas) > 0 {
+			itemsSchema = (*Schema)(&schema.Items.Schemas[0])
+		}
+		if itemsSchema == nil {
+			return nil
+		}
+		for i, item := range o {
+			err = itemsSchema.ValidateEnums(fmt.Sprintf("%s[%d]", path, i), item, swagger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonType returns the json type of the decoded object, telling integers from other numbers.
+func jsonType(object interface{}) string {
+	switch o := object.(type) {
+	case bool:
+		return gojsonschema.TYPE_BOOLEAN
+	case json.Number:
+		if strings.ContainsAny(o.String(), ".eE") {
+			return gojsonschema.TYPE_NUMBER
+		}
+		return gojsonschema.TYPE_INTEGER
+	case float32, float64:
+		if f := reflect.ValueOf(o).Float(); f != math.Trunc(f) {
+			return gojsonschema.TYPE_NUMBER
+		}
+		return gojsonschema.TYPE_INTEGER
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return gojsonschema.TYPE_INTEGER
+	case string:
+		return gojsonschema.TYPE_STRING
+	case map[string]interface{}:
+		return gojsonschema.TYPE_OBJECT
+	case []interface{}:
+		return gojsonschema.TYPE_ARRAY
+	}
+	return fmt.Sprintf("%T", object)
+}
+
+// Violations returns all the places where the object doesn't conform to the schema, each as the JSON path
+// of the offending field from path followed by what's wrong, e.g. "$.tags[0].name: expecting string, got
+// integer". Unlike Parses, it doesn't stop at the first mismatch, and doesn't mind the undeclared fields.
+func (schema *Schema) Violations(path string, object interface{}, swagger *Swagger) []string {
+	if object == nil {
+		return nil
+	}
+	_, referredSchema, err := swagger.GetReferredSchema(schema)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", path, err.Error())}
+	}
+	if referredSchema != nil {
+		return referredSchema.Violations(path, object, swagger)
+	}
+	var violations []string
+	for _, s := range schema.AllOf {
+		violations = append(violations, ((*Schema)(&s)).Violations(path, object, swagger)...)
+	}
+
+	objType := jsonType(object)
+	if len(schema.Type) > 0 {
+		matches := schema.Type.Contains(objType) ||
+			(objType == gojsonschema.TYPE_INTEGER && schema.Type.Contains(gojsonschema.TYPE_NUMBER))
+		if !matches {
+			return append(violations, fmt.Sprintf("%s: expecting %s, got %s", path, strings.Join(schema.Type, " or "), objType))
+		}
+	}
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, object) {
+		violations = append(violations, fmt.Sprintf("%s: %v is not one of the allowed values %v", path, object, schema.Enum))
+	}
+
+	switch o := object.(type) {
+	case map[string]interface{}:
+		for _, requiredName := range schema.Required {
+			if _, exist := o[requiredName]; !exist {
+				violations = append(violations, fmt.Sprintf("%s.%s: required field not present", path, requiredName))
+			}
+		}
+		var names []string
+		for k := range o {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if propertySchema, ok := schema.Properties[k]; ok {
+				violations = append(violations, ((*Schema)(&propertySchema)).Violations(path+"."+k, o[k], swagger)...)
+			} else if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+				violations = append(violations, ((*Schema)(schema.AdditionalProperties.Schema)).Violations(path+"."+k, o[k], swagger)...)
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil {
+			break
+		}
+		itemsSchema := (*Schema)(schema.Items.Schema)
+		if itemsSchema == nil && len(schema.Items.Schemas) > 0 {
+			itemsSchema = (*Schema)(&schema.Items.Schemas[0])
+		}
+		if itemsSchema == nil {
+			break
+		}
+		for i, item := range o {
+			violations = append(violations, itemsSchema.Violations(fmt.Sprintf("%s[%d]", path, i), item, swagger)...)
+		}
+	}
+	return violations
+}
+
 func (schema *Schema) Contains(name string, swagger *Swagger) bool {
 	iterFunc := func(swagger *Swagger, schemaName string, schema *Schema, context interface{}) error {
 		// The only way we have to abort is through an error.
@@ -311,6 +502,8 @@ func (schema *Schema) Iterate(iterFunc SchemaIterator, context interface{}, swag
 type DBEntry struct {
 	Data         map[string]interface{}            // The object itself.
 	Associations map[string]map[string]interface{} // The objects associated with this object. Class to object map.
+	PathParams   map[string]interface{}            // The path parameters used when the object was created or fetched.
+	Seeded       bool                              // The object came from the seed file rather than a test.
 }
 
 func (entry *DBEntry) Matches(criteria interface{}, associations map[string]map[string]interface{}, matches MatchFunc) bool {
@@ -331,18 +524,29 @@ type SchemaDB struct {
 	Objects   []*DBEntry
 }
 
-// Insert inserts an object into the schema's object list.
-func (db *SchemaDB) Insert(obj interface{}, associations map[string]map[string]interface{}) error {
+// Insert inserts an object into the schema's object list. The pathParams are the ones used in the
+// REST call. They allow us to reconstruct the full path of resources addressed by composite keys.
+func (db *SchemaDB) Insert(obj interface{}, associations map[string]map[string]interface{}, pathParams map[string]interface{}) error {
 	if !db.NoHistory {
 		found := db.Find(obj, associations, mqutil.InterfaceEquals, 1)
 		if len(found) == 0 {
-			dbentry := &DBEntry{obj.(map[string]interface{}), associations}
+			dbentry := &DBEntry{obj.(map[string]interface{}), associations, mqutil.MapCopy(pathParams), false}
 			db.Objects = append(db.Objects, dbentry)
 		}
 	}
 	return nil
 }
 
+// GetPathParams returns the path parameters stored with the object.
+func (db *SchemaDB) GetPathParams(obj interface{}) map[string]interface{} {
+	for _, entry := range db.Objects {
+		if mqutil.InterfaceEquals(obj, entry.Data) {
+			return entry.PathParams
+		}
+	}
+	return nil
+}
+
 // MatchFunc checks whether the input criteria and an input object matches.
 type MatchFunc func(criteria interface{}, existing interface{}) bool
 
@@ -394,7 +598,9 @@ func (db *SchemaDB) Update(criteria interface{}, associations map[string]map[str
 	for _, entry := range db.Objects {
 		if entry.Matches(criteria, associations, matches) {
 			if patch {
-				mqutil.MapCombine(entry.Data, newObj)
+				// The tests running at the same time may be reading the old object, it's replaced rather
+				// than changed.
+				entry.Data = mqutil.MapCombine(mqutil.MapCopy(entry.Data), newObj)
 			} else {
 				entry.Data = newObj
 			}
@@ -411,6 +617,9 @@ type DB struct {
 	schemas map[string](*SchemaDB)
 	Swagger *Swagger
 	mutex   sync.Mutex // We don't expect much contention, as such mutex will be fast
+
+	seeds map[string][]map[string]interface{} // The objects of the seed file, by definition name.
+	state map[string][]*DBEntry               // The objects loaded from the state file, by definition name.
 }
 
 // TODO it seems that if an object is not being used as a parameter to any operation, we don't
@@ -422,21 +631,25 @@ func (db *DB) Init(s *Swagger) {
 	db.schemas = make(map[string](*SchemaDB))
 	for schemaName, schema := range s.Definitions {
 		if _, ok := db.schemas[schemaName]; ok {
-			mqutil.Logger.Printf("warning - schema %s already exists", schemaName)
+			mqutil.Warnf("schema %s already exists", schemaName)
 		}
 		// Note that schema variable is reused in the loop
 		schemaCopy := schema
 		db.schemas[schemaName] = &SchemaDB{schemaName, (*Schema)(&schemaCopy), false, nil}
 	}
+	db.insertSeeds()
+	db.insertState()
 }
 
 // Clone the db but not the objects
 func (db *DB) CloneSchema() *DB {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
 	schemas := make(map[string]*SchemaDB)
 	for k, v := range db.schemas {
 		schemas[k] = v.CloneSchema()
 	}
-	return &DB{schemas, db.Swagger, sync.Mutex{}}
+	return &DB{schemas: schemas, Swagger: db.Swagger}
 }
 
 func (db *DB) GetSchema(name string) *Schema {
@@ -458,14 +671,24 @@ func CopyWithoutClass(src map[string]map[string]interface{}, className string) m
 	return dst
 }
 
-func (db *DB) Insert(name string, obj interface{}, associations map[string]map[string]interface{}) error {
+func (db *DB) Insert(name string, obj interface{}, associations map[string]map[string]interface{}, pathParams map[string]interface{}) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
 	if db.schemas[name] == nil {
 		return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("inserting into non-existing schema: %s", name))
 	}
-	return db.schemas[name].Insert(obj, CopyWithoutClass(associations, name))
+	return db.schemas[name].Insert(obj, CopyWithoutClass(associations, name), pathParams)
+}
+
+func (db *DB) GetPathParams(name string, obj interface{}) map[string]interface{} {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.schemas[name] == nil {
+		return nil
+	}
+	return db.schemas[name].GetPathParams(obj)
 }
 
 func (db *DB) Find(name string, criteria interface{}, associations map[string]map[string]interface{},
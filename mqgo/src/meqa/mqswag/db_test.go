@@ -0,0 +1,198 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqutil"
+)
+
+func schemaOf(types ...string) *Schema {
+	return &Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray(types)}}
+}
+
+func TestIntegerTypes(t *testing.T) {
+	if !schemaOf("integer").isIntegerOnly() || schemaOf("number").isIntegerOnly() ||
+		schemaOf("integer", "number").isIntegerOnly() || schemaOf("string").isIntegerOnly() {
+		t.Errorf("isIntegerOnly is wrong")
+	}
+
+	cases := []struct {
+		object   interface{}
+		jsonType string
+		integer  bool // whether it parses as an integer field
+		number   bool // and as a number field
+	}{
+		{json.Number("1"), "integer", true, true},
+		{json.Number("-12"), "integer", true, true},
+		{json.Number("1.0"), "number", false, true},
+		{json.Number("1.5"), "number", false, true},
+		{json.Number("1e3"), "number", false, true},
+		{json.Number("1E-3"), "number", false, true},
+		// Without UseNumber 1.0 can't be told from 1.
+		{float64(1), "integer", true, true},
+		{float64(1.5), "number", false, true},
+		{float32(2.25), "number", false, true},
+		{3, "integer", true, true},
+		{int64(3), "integer", true, true},
+		{uint8(3), "integer", true, true},
+		{"1", "string", false, false},
+		{true, "boolean", false, false},
+		{map[string]interface{}{}, "object", false, false},
+		{[]interface{}{}, "array", false, false},
+	}
+	for _, c := range cases {
+		if jt := jsonType(c.object); jt != c.jsonType {
+			t.Errorf("jsonType(%#v) = %s, expecting %s", c.object, jt, c.jsonType)
+		}
+		if parses := schemaOf("integer").Matches(c.object, &Swagger{}); parses != c.integer {
+			t.Errorf("%#v matching an integer = %t, expecting %t", c.object, parses, c.integer)
+		}
+		if parses := schemaOf("number").Matches(c.object, &Swagger{}); parses != c.number {
+			t.Errorf("%#v matching a number = %t, expecting %t", c.object, parses, c.number)
+		}
+	}
+	if jt := jsonType(nil); jt != "<nil>" {
+		t.Errorf("jsonType(nil) = %s", jt)
+	}
+}
+
+// petSwagger returns a swagger with a Pet that has enums at the top, in a nested object and in an array.
+func petSwagger() (*Swagger, *Schema) {
+	property := func(types string, enum ...interface{}) spec.Schema {
+		return spec.Schema{SchemaProps: spec.SchemaProps{Type: spec.StringOrArray{types}, Enum: enum}}
+	}
+	tag := spec.Schema{SchemaProps: spec.SchemaProps{
+		Type: spec.StringOrArray{"object"},
+		Properties: map[string]spec.Schema{
+			"name":  property("string"),
+			"level": property("integer", float64(1), float64(2)),
+		},
+	}}
+	pet := spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:     spec.StringOrArray{"object"},
+		Required: []string{"id", "name"},
+		Properties: map[string]spec.Schema{
+			"id":     property("integer"),
+			"name":   property("string"),
+			"status": property("string", "available", "sold"),
+			"owner": {SchemaProps: spec.SchemaProps{
+				Type:       spec.StringOrArray{"object"},
+				Properties: map[string]spec.Schema{"kind": property("string", "person", "shop")},
+			}},
+			"tags": {SchemaProps: spec.SchemaProps{
+				Type: spec.StringOrArray{"array"},
+				Items: &spec.SchemaOrArray{Schema: &spec.Schema{
+					SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Tag")}}},
+			}},
+		},
+	}}
+	swagger := &Swagger{SwaggerProps: spec.SwaggerProps{Definitions: spec.Definitions{"Pet": pet, "Tag": tag}}}
+	return swagger, &Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Pet")}}
+}
+
+// decode decodes the json the way the responses are, with the numbers as json.Number.
+func decode(t *testing.T, s string) interface{} {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var object interface{}
+	if err := d.Decode(&object); err != nil {
+		t.Fatalf("can't decode %s: %s", s, err)
+	}
+	return object
+}
+
+func TestValidateEnums(t *testing.T) {
+	swagger, pet := petSwagger()
+	cases := []struct {
+		object string
+		err    string
+	}{
+		{`{"id": 1, "name": "a", "status": "sold"}`, ""},
+		{`{"id": 1, "name": "a"}`, ""},
+		{`{"id": 1, "name": "a", "owner": {"kind": "shop"}, "tags": [{"level": 1}, {"level": 2.0}]}`, ""},
+		// Only the enums are checked, not the types or the required fields.
+		{`{"id": "x", "status": "available"}`, ""},
+		{`{"id": 1, "name": "a", "status": "pending"}`,
+			"$.status has value pending, which is not one of the allowed values [available sold]"},
+		{`{"id": 1, "name": "a", "status": "Sold"}`,
+			"$.status has value Sold, which is not one of the allowed values [available sold]"},
+		{`{"id": 1, "name": "a", "owner": {"kind": "robot"}}`,
+			"$.owner.kind has value robot, which is not one of the allowed values [person shop]"},
+		{`{"id": 1, "name": "a", "tags": [{"level": 1}, {"level": 3}]}`,
+			"$.tags[1].level has value 3, which is not one of the allowed values [1 2]"},
+		// A string isn't equal to the number it spells.
+		{`{"id": 1, "name": "a", "tags": [{"level": "1"}]}`,
+			"$.tags[0].level has value 1, which is not one of the allowed values [1 2]"},
+	}
+	for _, c := range cases {
+		err := pet.ValidateEnums("$", decode(t, c.object), swagger)
+		if len(c.err) == 0 && err != nil {
+			t.Errorf("%s: unexpected error %s", c.object, err)
+		} else if len(c.err) > 0 && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: got %v, expecting %s", c.object, err, c.err)
+		} else if err != nil && err.(mqutil.Error).Type() != mqutil.ErrServerResp {
+			t.Errorf("%s: got error type %d", c.object, err.(mqutil.Error).Type())
+		}
+	}
+
+	// The enums decoded without UseNumber are float64 too.
+	var object interface{}
+	json.Unmarshal([]byte(`{"tags": [{"level": 2}]}`), &object)
+	if err := pet.ValidateEnums("$", object, swagger); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	swagger, pet := petSwagger()
+	cases := []struct {
+		object     string
+		violations []string
+	}{
+		{`{"id": 1, "name": "a", "status": "sold", "owner": {"kind": "shop"}, "tags": [{"name": "b", "level": 2}]}`, nil},
+		// The undeclared fields are fine.
+		{`{"id": 1, "name": "a", "color": "red"}`, nil},
+		{`{"name": "a"}`, []string{"$.id: required field not present"}},
+		{`{}`, []string{"$.id: required field not present", "$.name: required field not present"}},
+		{`{"id": 1.0, "name": "a"}`, []string{"$.id: expecting integer, got number"}},
+		{`{"id": 1e3, "name": "a"}`, []string{"$.id: expecting integer, got number"}},
+		{`{"id": "1", "name": 2}`, []string{"$.id: expecting integer, got string", "$.name: expecting string, got integer"}},
+		{`{"id": 1, "name": "a", "status": "pending"}`, []string{"$.status: pending is not one of the allowed values [available sold]"}},
+		{`[]`, []string{"$: expecting object, got array"}},
+		// All the violations are reported, with the paths into the nested objects and arrays.
+		{`{"id": 1, "owner": {"kind": "robot"}, "tags": [{"name": "b"}, {"name": 3, "level": 1.5}, "c"]}`, []string{
+			"$.name: required field not present",
+			"$.owner.kind: robot is not one of the allowed values [person shop]",
+			"$.tags[1].level: expecting integer, got number",
+			"$.tags[1].name: expecting string, got integer",
+			"$.tags[2]: expecting object, got string",
+		}},
+	}
+	for _, c := range cases {
+		violations := pet.Violations("$", decode(t, c.object), swagger)
+		if !reflect.DeepEqual(violations, c.violations) {
+			t.Errorf("%s: got %q, expecting %q", c.object, violations, c.violations)
+		}
+	}
+
+	// An integer is a number, and a string map checks all its values.
+	numbers := &Schema{SchemaProps: spec.SchemaProps{
+		Type:                 spec.StringOrArray{"object"},
+		AdditionalProperties: &spec.SchemaOrBool{Allows: true, Schema: (*spec.Schema)(schemaOf("number"))},
+	}}
+	violations := numbers.Violations("$", decode(t, `{"a": 1, "b": 1.5, "c": "x"}`), swagger)
+	if !reflect.DeepEqual(violations, []string{"$.c: expecting number, got string"}) {
+		t.Errorf("got %q", violations)
+	}
+
+	missing := &Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef("#/definitions/Missing")}}
+	violations = missing.Violations("$", decode(t, `{}`), swagger)
+	if len(violations) != 1 || !strings.HasPrefix(violations[0], "$: ") || !strings.Contains(violations[0], "Reference object not found") {
+		t.Errorf("got %q", violations)
+	}
+}
@@ -0,0 +1,150 @@
+package mqswag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"meqa/mqutil"
+)
+
+// MatchRules override the <meqa Class.property> tags on parameters and object properties, which tell
+// us which object's field a parameter takes its value from. The key is the parameter or property name.
+// MatchRules implements flag.Value so it can be set through repeated "-match-rule ownerId=User.id".
+type MatchRules map[string]*MeqaTag
+
+func (rules MatchRules) String() string {
+	var list []string
+	for name, tag := range rules {
+		list = append(list, name+"="+tag.Class+"."+tag.Property)
+	}
+	return strings.Join(list, ",")
+}
+
+func (rules MatchRules) Set(rule string) error {
+	kv := strings.SplitN(rule, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("invalid match rule %s, the format is param=Class.property", rule)
+	}
+	ar := strings.Split(kv[1], ".")
+	if len(kv[0]) == 0 || len(ar) != 2 || len(ar[0]) == 0 || len(ar[1]) == 0 {
+		return fmt.Errorf("invalid match rule %s, the format is param=Class.property", rule)
+	}
+	rules[kv[0]] = &MeqaTag{Class: ar[0], Property: ar[1]}
+	return nil
+}
+
+// The match rules applied to every swagger we load. When ExactTagMatch is set, we drop the tags
+// that don't match the name exactly, see exactTagMatch.
+var TagRules = make(MatchRules)
+var ExactTagMatch bool
+
+// replaceMeqaTag replaces the meqa tag in the description with the new tag, or removes it if the new tag is nil.
+func replaceMeqaTag(desc string, tag *MeqaTag) string {
+	desc = strings.TrimSpace(meqaTagRegex.ReplaceAllString(desc, ""))
+	if tag == nil {
+		return desc
+	}
+	if len(desc) > 0 {
+		desc += " "
+	}
+	return desc + tag.ToString()
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
+
+// exactTagMatch checks whether the name refers to the tag's definition and property without guessing,
+// e.g. id and petId both match Pet.id, but ownerId doesn't match User.id.
+func exactTagMatch(name string, tag *MeqaTag) bool {
+	n := normalizeName(name)
+	p := normalizeName(tag.Property)
+	return n == p || n == normalizeName(tag.Class)+p
+}
+
+// applyMatchRule returns the new description of the named parameter or property.
+func applyMatchRule(name string, desc string) string {
+	if tag, ok := TagRules[name]; ok {
+		return replaceMeqaTag(desc, tag)
+	}
+	if ExactTagMatch {
+		tag := GetMeqaTag(desc)
+		if tag != nil && len(tag.Property) > 0 && !exactTagMatch(name, tag) {
+			mqutil.Logger.Printf("dropping tag %s on %s, it's not an exact match", tag.ToString(), name)
+			return replaceMeqaTag(desc, nil)
+		}
+	}
+	return desc
+}
+
+func applyMatchRulesToSchema(schema *spec.Schema) {
+	if schema == nil {
+		return
+	}
+	for name, property := range schema.Properties {
+		property.Description = applyMatchRule(name, property.Description)
+		applyMatchRulesToSchema(&property)
+		schema.Properties[name] = property
+	}
+	if schema.Items != nil {
+		applyMatchRulesToSchema(schema.Items.Schema)
+		for i := range schema.Items.Schemas {
+			applyMatchRulesToSchema(&schema.Items.Schemas[i])
+		}
+	}
+	for i := range schema.AllOf {
+		applyMatchRulesToSchema(&schema.AllOf[i])
+	}
+}
+
+func applyMatchRulesToParams(params []spec.Parameter) {
+	for i := range params {
+		if params[i].In == "body" {
+			applyMatchRulesToSchema(params[i].Schema)
+		} else {
+			params[i].Description = applyMatchRule(params[i].Name, params[i].Description)
+		}
+	}
+}
+
+// ApplyMatchRules applies TagRules and ExactTagMatch to the parameters and the definitions.
+func (swagger *Swagger) ApplyMatchRules() error {
+	for name, tag := range TagRules {
+		if swagger.FindSchemaByName(tag.Class) == nil {
+			return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("match rule %s=%s.%s: definition %s not found",
+				name, tag.Class, tag.Property, tag.Class))
+		}
+	}
+	if len(TagRules) == 0 && !ExactTagMatch {
+		return nil
+	}
+
+	for name, param := range swagger.Parameters {
+		p := []spec.Parameter{param}
+		applyMatchRulesToParams(p)
+		swagger.Parameters[name] = p[0]
+	}
+	for name, schema := range swagger.Definitions {
+		applyMatchRulesToSchema(&schema)
+		swagger.Definitions[name] = schema
+	}
+	if swagger.Paths == nil {
+		return nil
+	}
+	for pathName, pathItem := range swagger.Paths.Paths {
+		applyMatchRulesToParams(pathItem.Parameters)
+		for _, method := range MethodAll {
+			opInterface, err := pathItem.JSONLookup(method)
+			if err != nil {
+				return err
+			}
+			if op := opInterface.(*spec.Operation); op != nil {
+				applyMatchRulesToParams(op.Parameters)
+			}
+		}
+		swagger.Paths.Paths[pathName] = pathItem
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"meqa/mqutil"
+)
+
+// OperationPattern matches the operations of a swagger by "METHOD /path", e.g. "GET /orders/*".
+type OperationPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// OperationPatterns are the -include or -exclude patterns of generate. A pattern that starts with ^ is
+// a regular expression, the others are globs where * matches any characters, / included, and ? one
+// character other than /. A glob without a method matches the path for every method. OperationPatterns
+// implements flag.Value so it can be set through repeated flags.
+type OperationPatterns []*OperationPattern
+
+func (patterns *OperationPatterns) String() string {
+	var list []string
+	for _, p := range *patterns {
+		list = append(list, p.pattern)
+	}
+	return strings.Join(list, ",")
+}
+
+func (patterns *OperationPatterns) Set(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) == 0 {
+		return fmt.Errorf("empty operation pattern, the format is \"METHOD /path\", e.g. \"GET /orders/*\"")
+	}
+	re, err := compileOperationPattern(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid operation pattern %s: %s", pattern, err.Error())
+	}
+	*patterns = append(*patterns, &OperationPattern{pattern, re})
+	return nil
+}
+
+func compileOperationPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, "^") {
+		return regexp.Compile(pattern)
+	}
+	method, path := "*", pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		method, path = strings.ToUpper(pattern[:i]), strings.TrimSpace(pattern[i+1:])
+	}
+	return regexp.Compile("^" + globRegex(method) + " " + globRegex(path) + "$")
+}
+
+func globRegex(glob string) string {
+	return strings.NewReplacer(`\*`, ".*", `\?`, "[^/]").Replace(regexp.QuoteMeta(glob))
+}
+
+// Matches returns whether one of the patterns matches the operation.
+func (patterns OperationPatterns) Matches(method string, path string) bool {
+	operation := strings.ToUpper(method) + " " + path
+	for _, p := range patterns {
+		if p.re.MatchString(operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// The keys of a path item that are operations.
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// FilterOperations keeps the operations of the swagger, in json or yaml, that match one of the include
+// patterns, every operation when there are none, and don't match any of the exclude patterns. Exclude
+// wins when both match. The definitions, parameters and responses that only the removed operations
+// refer to are removed too. Returns the swagger as json, and the number of operations removed.
+func FilterOperations(swaggerBytes []byte, include OperationPatterns, exclude OperationPatterns) ([]byte, int, error) {
+	jsonBytes := swaggerBytes
+	var err error
+	if !looksLikeJSON(swaggerBytes) {
+		jsonBytes, err = mqutil.YamlToJson(swaggerBytes)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	var doc map[string]interface{}
+	if err = json.Unmarshal(jsonBytes, &doc); err != nil {
+		return nil, 0, err
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	removed, kept := 0, 0
+	for path, item := range paths {
+		pathItem, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		methods := 0
+		for _, method := range operationMethods {
+			if _, ok := pathItem[method]; !ok {
+				continue
+			}
+			if (len(include) > 0 && !include.Matches(method, path)) || exclude.Matches(method, path) {
+				delete(pathItem, method)
+				removed++
+			} else {
+				methods++
+			}
+		}
+		if methods == 0 {
+			delete(paths, path)
+		}
+		kept += methods
+	}
+	if kept == 0 {
+		return nil, removed, mqutil.NewError(mqutil.ErrInvalid, "no operation of the swagger matches the -include and -exclude patterns")
+	}
+	if removed > 0 {
+		pruneUnreferenced(doc)
+	}
+	jsonBytes, err = json.Marshal(doc)
+	return jsonBytes, removed, err
+}
+
+// pruneUnreferenced removes the definitions, parameters and responses that the paths don't refer to,
+// directly or through each other.
+func pruneUnreferenced(doc map[string]interface{}) {
+	sections := []string{"definitions", "parameters", "responses"}
+	// By section/name, the refs can point inside the object, e.g. #/definitions/Pet/properties/tag.
+	referenced := make(map[string]bool)
+	var walk func(obj interface{})
+	walk = func(obj interface{}) {
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			if ref, ok := o["$ref"].(string); ok && strings.HasPrefix(ref, "#/") {
+				tokens := strings.SplitN(ref[2:], "/", 3)
+				if len(tokens) >= 2 {
+					name := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[1])
+					if key := tokens[0] + "/" + name; !referenced[key] {
+						referenced[key] = true
+						if section, ok := doc[tokens[0]].(map[string]interface{}); ok {
+							walk(section[name])
+						}
+					}
+				}
+			}
+			for _, v := range o {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range o {
+				walk(v)
+			}
+		}
+	}
+	walk(doc["paths"])
+	for _, sectionName := range sections {
+		section, ok := doc[sectionName].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name := range section {
+			if !referenced[sectionName+"/"+name] {
+				delete(section, name)
+				mqutil.Logger.Printf("removed %s %s, no operation left refers to it", sectionName, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"meqa/mqutil"
+)
+
+func TestOperationPatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		method  string
+		path    string
+		matches bool
+	}{
+		{"GET /orders", "get", "/orders", true},
+		{"GET /orders", "post", "/orders", false},
+		{"get /orders", "GET", "/orders", true},
+		{"GET /orders", "get", "/orders/{id}", false},
+		{"GET /orders/*", "get", "/orders/{id}", true},
+		{"GET /orders/*", "get", "/orders/{id}/items", true},
+		{"GET /orders/*", "get", "/orders", false},
+		{"GET /orders*", "get", "/orders", true},
+		{"/orders/?", "delete", "/orders/1", true},
+		{"/orders/?", "delete", "/orders/12", false},
+		{"/orders/?x", "delete", "/orders//x", false},
+		{"/orders*", "patch", "/orders/1", true},
+		{"* /pets", "put", "/pets", true},
+		{"/pets.json", "get", "/petsxjson", false},
+		{"/pets(1)", "get", "/pets(1)", true},
+		{"^(GET|PUT) /orders/", "put", "/orders/1", true},
+		{"^(GET|PUT) /orders/", "post", "/orders/1", false},
+		{"^GET /orders$", "get", "/orders/1", false},
+	}
+	for _, c := range cases {
+		var patterns OperationPatterns
+		if err := patterns.Set(c.pattern); err != nil {
+			t.Errorf("%s: %s", c.pattern, err.Error())
+			continue
+		}
+		if matches := patterns.Matches(c.method, c.path); matches != c.matches {
+			t.Errorf("%s matching %s %s = %t, expecting %t", c.pattern, c.method, c.path, matches, c.matches)
+		}
+	}
+
+	for _, pattern := range []string{"", "  ", "^(GET"} {
+		var patterns OperationPatterns
+		if err := patterns.Set(pattern); err == nil {
+			t.Errorf("expecting pattern %q to be invalid", pattern)
+		}
+	}
+}
+
+const filterSwagger = `{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "get": {"responses": {"200": {"schema": {"type": "array", "items": {"$ref": "#/definitions/Pet"}}}}},
+      "post": {"parameters": [{"$ref": "#/parameters/pet"}], "responses": {"default": {"$ref": "#/responses/error"}}}
+    },
+    "/orders": {
+      "get": {"responses": {"200": {"schema": {"$ref": "#/definitions/Order/properties/items"}}}}
+    },
+    "/users/{id}": {
+      "delete": {"responses": {"200": {"schema": {"$ref": "#/definitions/User"}}}}
+    }
+  },
+  "definitions": {
+    "Pet": {"properties": {"tag": {"$ref": "#/definitions/Tag"}}},
+    "Tag": {"type": "object"},
+    "Order": {"properties": {"items": {"type": "array", "items": {"$ref": "#/definitions/Item"}}}},
+    "Item": {"type": "object"},
+    "User": {"type": "object"},
+    "Error": {"type": "object"}
+  },
+  "parameters": {"pet": {"name": "pet", "in": "body", "schema": {"$ref": "#/definitions/Pet"}}},
+  "responses": {"error": {"schema": {"$ref": "#/definitions/Error"}}}
+}`
+
+func sectionKeys(doc map[string]interface{}, section string) []string {
+	var keys []string
+	m, _ := doc[section].(map[string]interface{})
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestFilterOperations(t *testing.T) {
+	cases := []struct {
+		name        string
+		include     []string
+		exclude     []string
+		removed     int
+		paths       []string
+		definitions []string
+		parameters  []string
+		responses   []string
+	}{
+		{"no patterns", nil, nil, 0,
+			[]string{"/orders", "/pets", "/users/{id}"},
+			[]string{"Error", "Item", "Order", "Pet", "Tag", "User"}, []string{"pet"}, []string{"error"}},
+		{"include a path", []string{"/pets"}, nil, 2,
+			[]string{"/pets"}, []string{"Error", "Pet", "Tag"}, []string{"pet"}, []string{"error"}},
+		{"include a method", []string{"GET /pets"}, nil, 3,
+			[]string{"/pets"}, []string{"Pet", "Tag"}, nil, nil},
+		{"a ref inside a definition", []string{"GET /orders"}, nil, 3,
+			[]string{"/orders"}, []string{"Item", "Order"}, nil, nil},
+		{"exclude wins", []string{"/pets", "/orders"}, []string{"POST /pets", "/orders"}, 3,
+			[]string{"/pets"}, []string{"Pet", "Tag"}, nil, nil},
+		{"exclude only", nil, []string{"GET *"}, 2,
+			[]string{"/pets", "/users/{id}"}, []string{"Error", "Pet", "Tag", "User"}, []string{"pet"}, []string{"error"}},
+	}
+	for _, c := range cases {
+		var include, exclude OperationPatterns
+		for _, p := range c.include {
+			include.Set(p)
+		}
+		for _, p := range c.exclude {
+			exclude.Set(p)
+		}
+		filtered, removed, err := FilterOperations([]byte(filterSwagger), include, exclude)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+			continue
+		}
+		var doc map[string]interface{}
+		if err = json.Unmarshal(filtered, &doc); err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+			continue
+		}
+		if removed != c.removed {
+			t.Errorf("%s: removed %d operations, expecting %d", c.name, removed, c.removed)
+		}
+		for section, expected := range map[string][]string{
+			"paths": c.paths, "definitions": c.definitions, "parameters": c.parameters, "responses": c.responses,
+		} {
+			if keys := sectionKeys(doc, section); !reflect.DeepEqual(keys, expected) {
+				t.Errorf("%s: kept %s %v, expecting %v", c.name, section, keys, expected)
+			}
+		}
+	}
+
+	var include OperationPatterns
+	include.Set("/stores")
+	if _, _, err := FilterOperations([]byte(filterSwagger), include, nil); err == nil {
+		t.Errorf("expecting an error when no operation matches")
+	}
+}
+
+func TestMain(m *testing.M) {
+	mqutil.Logger = mqutil.NewStdLogger()
+	os.Exit(m.Run())
+}
@@ -2,6 +2,7 @@
 package mqswag
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"meqa/mqutil"
@@ -36,6 +37,66 @@ const (
 
 var MethodAll []string = []string{MethodGet, MethodPut, MethodPost, MethodDelete, MethodHead, MethodPatch, MethodOptions}
 
+// AllowsAnonymous returns whether the operation can be called both with and without credentials while
+// the swagger requires authentication globally. That's either an empty requirement ({}) among the
+// operation's security requirements, or an empty security list overriding the global one.
+func (swagger *Swagger) AllowsAnonymous(op *spec.Operation) bool {
+	if op == nil || len(swagger.Security) == 0 || op.Security == nil {
+		return false
+	}
+	if len(op.Security) == 0 {
+		return true
+	}
+	for _, requirement := range op.Security {
+		if len(requirement) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeySchemes returns the apiKey security schemes the operation can be called with, by their definition
+// name. The operation's security requirements override the global ones.
+func (swagger *Swagger) APIKeySchemes(op *spec.Operation) map[string]*spec.SecurityScheme {
+	requirements := swagger.Security
+	if op != nil && op.Security != nil {
+		requirements = op.Security
+	}
+	schemes := make(map[string]*spec.SecurityScheme)
+	for _, requirement := range requirements {
+		for name := range requirement {
+			if scheme, ok := swagger.SecurityDefinitions[name]; ok && scheme != nil && scheme.Type == "apiKey" {
+				schemes[name] = scheme
+			}
+		}
+	}
+	return schemes
+}
+
+// IsSafeOperation returns whether calling the operation leaves the server unchanged, i.e. it's a get or
+// head, and it doesn't document side effects through the x-side-effects extension.
+func IsSafeOperation(method string, op *spec.Operation) bool {
+	if method != MethodGet && method != MethodHead {
+		return false
+	}
+	if op == nil {
+		return true
+	}
+	sideEffects, ok := op.Extensions["x-side-effects"]
+	if !ok || sideEffects == nil {
+		return true
+	}
+	switch v := sideEffects.(type) {
+	case bool:
+		return !v
+	case string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
 const (
 	FlagSuccess = 1 << iota
 	FlagFail
@@ -65,14 +126,15 @@ func (t *MeqaTag) ToString() string {
 	return str
 }
 
+var meqaTagRegex = regexp.MustCompile("<meqa *[/-~\\-]+\\.?[/-~\\-]*\\.?[a-zA-Z]* *[a-zA-Z,]* *>")
+
 // GetMeqaTag extracts the <meqa > tags.
 // Example. for  <meqa Pet.Name.update>, return Pet, Name, update
 func GetMeqaTag(desc string) *MeqaTag {
 	if len(desc) == 0 {
 		return nil
 	}
-	re := regexp.MustCompile("<meqa *[/-~\\-]+\\.?[/-~\\-]*\\.?[a-zA-Z]* *[a-zA-Z,]* *>")
-	ar := re.FindAllString(desc, -1)
+	ar := meqaTagRegex.FindAllString(desc, -1)
 
 	// TODO it's possible that we have multiple choices because the server can't be
 	// certain. However, we only process one right now.
@@ -122,38 +184,61 @@ type Swagger spec.Swagger
 
 // Init from a file
 func CreateSwaggerFromURL(path string, meqaPath string) (*Swagger, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		mqutil.Errorf("can't read file %s", path)
+		return nil, err
+	}
+	return CreateSwaggerFromBytes(fileBytes, path, meqaPath)
+}
+
+// looksLikeJSON returns whether the document is json rather than yaml.
+func looksLikeJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// CreateSwaggerFromBytes parses the swagger document in json or yaml, e.g. one read from stdin. The
+// relative external $refs are resolved against path, which doesn't have to exist.
+func CreateSwaggerFromBytes(fileBytes []byte, path string, meqaPath string) (*Swagger, error) {
 	tmpPath := filepath.Join(meqaPath, ".meqatmp")
 	os.Remove(tmpPath)
 	tmpFile, err := os.Create(tmpPath)
 	if err != nil {
-		mqutil.Logger.Printf("can't access tmp file %s", tmpPath)
+		mqutil.Errorf("can't access tmp file %s", tmpPath)
 		return nil, err
 	}
 	defer os.Remove(tmpPath)
 
-	// If input is yaml, transform to json
-	var swaggerJsonPath string
+	// If input is yaml, transform to json. The targets of the external $refs are merged into the json, then
+	// the patch, if any, is applied to it.
+	jsonBytes := fileBytes
 	ar := strings.Split(path, ".")
-	if ar[len(ar)-1] == "json" {
-		swaggerJsonPath = path
-	} else {
-		yamlBytes, err := ioutil.ReadFile(path)
-		if err != nil {
-			mqutil.Logger.Printf("can't read file %s", path)
-			return nil, err
-		}
-		jsonBytes, err := mqutil.YamlToJson(yamlBytes)
+	if ar[len(ar)-1] != "json" && !looksLikeJSON(fileBytes) {
+		jsonBytes, err = mqutil.YamlToJson(fileBytes)
 		if err != nil {
 			mqutil.Logger.Printf("invalid yaml in file %s %v", path, err)
 			return nil, err
 		}
-		_, err = tmpFile.Write(jsonBytes)
+	}
+	jsonBytes, err = ResolveExternalRefs(jsonBytes, path)
+	if err != nil {
+		mqutil.Errorf("can't load the swagger %s: %v", path, err)
+		return nil, err
+	}
+	if len(SpecPatch) > 0 {
+		jsonBytes, err = ApplySpecPatch(jsonBytes)
 		if err != nil {
-			mqutil.Logger.Printf("can't access tmp file %s", tmpPath)
+			mqutil.Errorf("can't apply spec patch %s: %v", SpecPatch, err)
 			return nil, err
 		}
-		swaggerJsonPath = tmpPath
 	}
+	_, err = tmpFile.Write(jsonBytes)
+	if err != nil {
+		mqutil.Errorf("can't access tmp file %s", tmpPath)
+		return nil, err
+	}
+	swaggerJsonPath := tmpPath
 
 	specDoc, err := loads.Spec(swaggerJsonPath)
 	if err != nil {
@@ -164,7 +249,12 @@ func CreateSwaggerFromURL(path string, meqaPath string) (*Swagger, error) {
 
 	// log.Println("Would be serving:", specDoc.Spec().Info.Title)
 
-	return (*Swagger)(specDoc.Spec()), nil
+	swagger := (*Swagger)(specDoc.Spec())
+	err = swagger.ApplyMatchRules()
+	if err != nil {
+		return nil, err
+	}
+	return swagger, nil
 }
 
 // FindSchemaByName finds the schema defined by name in the swagger document.
@@ -0,0 +1,231 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"meqa/mqutil"
+)
+
+// SpecPatch is the file of the patch applied to every swagger we load, before it's parsed. A list is
+// taken as a JSON Patch (RFC 6902), an object as a JSON Merge Patch (RFC 7386). Yaml is fine too.
+var SpecPatch string
+
+// mergePatch applies the JSON Merge Patch to the target, returning the result.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{})
+	}
+	for k, v := range patchMap {
+		if v == nil {
+			delete(targetMap, k)
+		} else {
+			targetMap[k] = mergePatch(targetMap[k], v)
+		}
+	}
+	return targetMap
+}
+
+// pointerTokens splits the JSON pointer into its unescaped tokens.
+func pointerTokens(pointer string) ([]string, error) {
+	if len(pointer) == 0 {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %s", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	return i, nil
+}
+
+// getAt returns the value the tokens point to.
+func getAt(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(d), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("%s not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// patchAt adds, replaces or removes the value the tokens point to, returning the new document.
+func patchAt(doc interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, fmt.Errorf("can't remove the whole document")
+		}
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		child, ok := d[token]
+		if len(rest) > 0 || op != "add" {
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+		}
+		if len(rest) == 0 {
+			if op == "remove" {
+				delete(d, token)
+			} else {
+				d[token] = value
+			}
+			return d, nil
+		}
+		child, err := patchAt(child, rest, op, value)
+		if err != nil {
+			return nil, err
+		}
+		d[token] = child
+		return d, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(d), len(rest) == 0 && op == "add")
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			child, err := patchAt(d[i], rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = child
+			return d, nil
+		}
+		switch op {
+		case "add":
+			d = append(d, nil)
+			copy(d[i+1:], d[i:])
+			d[i] = value
+		case "remove":
+			d = append(d[:i], d[i+1:]...)
+		default:
+			d[i] = value
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("%s not found", token)
+}
+
+// jsonPatch applies the operations of the JSON Patch to the document, returning the result.
+func jsonPatch(doc interface{}, operations []interface{}) (interface{}, error) {
+	for _, o := range operations {
+		operation, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid patch operation %v", o)
+		}
+		op, _ := operation["op"].(string)
+		path, _ := operation["path"].(string)
+		tokens, err := pointerTokens(path)
+		if err != nil {
+			return nil, err
+		}
+		var fromTokens []string
+		if op == "move" || op == "copy" {
+			from, _ := operation["from"].(string)
+			fromTokens, err = pointerTokens(from)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch op {
+		case "add", "replace":
+			doc, err = patchAt(doc, tokens, op, operation["value"])
+		case "remove":
+			doc, err = patchAt(doc, tokens, op, nil)
+		case "move", "copy":
+			var value interface{}
+			value, err = getAt(doc, fromTokens)
+			if err == nil && op == "move" {
+				doc, err = patchAt(doc, fromTokens, "remove", nil)
+			} else if err == nil {
+				// Copy the value, later operations on either copy shouldn't affect the other.
+				data, _ := json.Marshal(value)
+				json.Unmarshal(data, &value)
+			}
+			if err == nil {
+				doc, err = patchAt(doc, tokens, "add", value)
+			}
+		case "test":
+			var value interface{}
+			value, err = getAt(doc, tokens)
+			if err == nil && !reflect.DeepEqual(value, operation["value"]) {
+				err = fmt.Errorf("test of %s failed", path)
+			}
+		default:
+			err = fmt.Errorf("unknown patch operation %s", op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %s", op, path, err.Error())
+		}
+	}
+	return doc, nil
+}
+
+// ApplySpecPatch applies the patch in the SpecPatch file to the json swagger document.
+func ApplySpecPatch(jsonBytes []byte) ([]byte, error) {
+	patchBytes, err := ioutil.ReadFile(SpecPatch)
+	if err != nil {
+		return nil, err
+	}
+	patchJson, err := mqutil.YamlToJson(patchBytes)
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid spec patch %s: %s", SpecPatch, err.Error()))
+	}
+	var patch, doc interface{}
+	err = json.Unmarshal(patchJson, &patch)
+	if err == nil {
+		err = json.Unmarshal(jsonBytes, &doc)
+	}
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't apply spec patch %s: %s", SpecPatch, err.Error()))
+	}
+
+	if operations, ok := patch.([]interface{}); ok {
+		doc, err = jsonPatch(doc, operations)
+		if err != nil {
+			return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't apply spec patch %s: %s", SpecPatch, err.Error()))
+		}
+	} else {
+		doc = mergePatch(doc, patch)
+	}
+	mqutil.Logger.Printf("applied spec patch %s:\n%s", SpecPatch, string(patchJson))
+	return json.Marshal(doc)
+}
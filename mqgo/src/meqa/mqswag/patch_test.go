@@ -0,0 +1,126 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid json %s: %s", s, err.Error())
+	}
+	return v
+}
+
+func TestPointerTokens(t *testing.T) {
+	cases := []struct {
+		pointer string
+		tokens  []string
+		fails   bool
+	}{
+		{"", nil, false},
+		{"/", []string{""}, false},
+		{"/paths/~1pets/get", []string{"paths", "/pets", "get"}, false},
+		{"/a~0b/~01", []string{"a~b", "~1"}, false},
+		{"paths", nil, true},
+	}
+	for _, c := range cases {
+		tokens, err := pointerTokens(c.pointer)
+		if (err != nil) != c.fails || !reflect.DeepEqual(tokens, c.tokens) {
+			t.Errorf("pointerTokens(%q) = %q, %v, expecting %q", c.pointer, tokens, err, c.tokens)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	cases := []struct {
+		name   string
+		doc    string
+		patch  string
+		result string // empty when the patch fails
+	}{
+		{"add a field", `{"a": 1}`, `[{"op": "add", "path": "/b", "value": 2}]`, `{"a": 1, "b": 2}`},
+		{"add replaces a field", `{"a": 1}`, `[{"op": "add", "path": "/a", "value": 2}]`, `{"a": 2}`},
+		{"add to a missing parent", `{"a": 1}`, `[{"op": "add", "path": "/b/c", "value": 2}]`, ""},
+		{"add inserts in an array", `{"a": [1, 3]}`, `[{"op": "add", "path": "/a/1", "value": 2}]`, `{"a": [1, 2, 3]}`},
+		{"add at the end of an array", `{"a": [1]}`, `[{"op": "add", "path": "/a/1", "value": 2}]`, `{"a": [1, 2]}`},
+		{"add appends with -", `{"a": [1]}`, `[{"op": "add", "path": "/a/-", "value": 2}]`, `{"a": [1, 2]}`},
+		{"add past the end of an array", `{"a": [1]}`, `[{"op": "add", "path": "/a/2", "value": 2}]`, ""},
+		{"add with a bad index", `{"a": [1]}`, `[{"op": "add", "path": "/a/x", "value": 2}]`, ""},
+		{"add the whole document", `{"a": 1}`, `[{"op": "add", "path": "", "value": {"b": 2}}]`, `{"b": 2}`},
+		{"add with escaped keys", `{"paths": {}}`, `[{"op": "add", "path": "/paths/~1pets", "value": {}}]`, `{"paths": {"/pets": {}}}`},
+		{"remove a field", `{"a": 1, "b": 2}`, `[{"op": "remove", "path": "/a"}]`, `{"b": 2}`},
+		{"remove a missing field", `{"a": 1}`, `[{"op": "remove", "path": "/b"}]`, ""},
+		{"remove from an array", `{"a": [1, 2, 3]}`, `[{"op": "remove", "path": "/a/1"}]`, `{"a": [1, 3]}`},
+		{"remove with -", `{"a": [1]}`, `[{"op": "remove", "path": "/a/-"}]`, ""},
+		{"remove the whole document", `{"a": 1}`, `[{"op": "remove", "path": ""}]`, ""},
+		{"replace a field", `{"a": {"b": 1}}`, `[{"op": "replace", "path": "/a/b", "value": 2}]`, `{"a": {"b": 2}}`},
+		{"replace a missing field", `{"a": 1}`, `[{"op": "replace", "path": "/b", "value": 2}]`, ""},
+		{"replace in an array", `{"a": [1, 2]}`, `[{"op": "replace", "path": "/a/0", "value": 3}]`, `{"a": [3, 2]}`},
+		{"replace past the end of an array", `{"a": [1]}`, `[{"op": "replace", "path": "/a/1", "value": 3}]`, ""},
+		{"move a field", `{"a": {"b": 1}, "c": {}}`, `[{"op": "move", "from": "/a/b", "path": "/c/d"}]`, `{"a": {}, "c": {"d": 1}}`},
+		{"move in an array", `{"a": [1, 2, 3]}`, `[{"op": "move", "from": "/a/0", "path": "/a/-"}]`, `{"a": [2, 3, 1]}`},
+		{"move a missing field", `{"a": 1}`, `[{"op": "move", "from": "/b", "path": "/c"}]`, ""},
+		{"copy a field", `{"a": {"b": 1}}`, `[{"op": "copy", "from": "/a", "path": "/c"}]`, `{"a": {"b": 1}, "c": {"b": 1}}`},
+		{"copy is deep", `{"a": {"b": 1}}`,
+			`[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "replace", "path": "/c/b", "value": 2}]`,
+			`{"a": {"b": 1}, "c": {"b": 2}}`},
+		{"copy into an array", `{"a": [1, 2], "b": 0}`, `[{"op": "copy", "from": "/b", "path": "/a/1"}]`, `{"a": [1, 0, 2], "b": 0}`},
+		{"test passes", `{"a": [1, {"b": "x"}]}`, `[{"op": "test", "path": "/a/1/b", "value": "x"}]`, `{"a": [1, {"b": "x"}]}`},
+		{"test fails", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}]`, ""},
+		{"test stops the patch", `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}, {"op": "remove", "path": "/a"}]`, ""},
+		{"operations in order", `{}`,
+			`[{"op": "add", "path": "/a", "value": []}, {"op": "add", "path": "/a/-", "value": 1}, {"op": "add", "path": "/a/0", "value": 0}]`,
+			`{"a": [0, 1]}`},
+		{"unknown operation", `{"a": 1}`, `[{"op": "frob", "path": "/a"}]`, ""},
+		{"invalid pointer", `{"a": 1}`, `[{"op": "remove", "path": "a"}]`, ""},
+	}
+	for _, c := range cases {
+		operations, _ := decodeJSON(t, c.patch).([]interface{})
+		result, err := jsonPatch(decodeJSON(t, c.doc), operations)
+		if len(c.result) == 0 {
+			if err == nil {
+				t.Errorf("%s: expecting an error, got %v", c.name, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+		} else if expected := decodeJSON(t, c.result); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: got %v, expecting %v", c.name, result, expected)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// The examples of RFC 7386.
+	cases := []struct {
+		doc    string
+		patch  string
+		result string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+		{`{"a": "b"}`, `["c"]`, `["c"]`},
+		{`{"a": "foo"}`, `null`, `null`},
+		{`{"a": "foo"}`, `"bar"`, `"bar"`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+	}
+	for _, c := range cases {
+		result := mergePatch(decodeJSON(t, c.doc), decodeJSON(t, c.patch))
+		if expected := decodeJSON(t, c.result); !reflect.DeepEqual(result, expected) {
+			t.Errorf("mergePatch(%s, %s) = %v, expecting %v", c.doc, c.patch, result, expected)
+		}
+	}
+}
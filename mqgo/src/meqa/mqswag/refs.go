@@ -0,0 +1,238 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/resty.v0"
+
+	"meqa/mqutil"
+)
+
+// The documents fetched for remote $refs, by url, so that we only download each once.
+var remoteRefCache = make(map[string][]byte)
+
+// refResolver merges the targets of the $refs to other files and urls into the swagger document, as
+// local definitions, parameters or responses.
+type refResolver struct {
+	root       map[string]interface{}
+	rootPath   string
+	docs       map[string]interface{} // the parsed documents by location
+	resolved   map[string]string      // the local refs by location#fragment
+	unresolved []string
+}
+
+// IsURL returns whether the location is an http or https url rather than a file.
+func IsURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// refLocation returns the location of the file in the ref, relative to the document at base.
+func refLocation(base string, file string) (string, error) {
+	if IsURL(file) {
+		return file, nil
+	}
+	if IsURL(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		fileURL, err := url.Parse(file)
+		if err != nil {
+			return "", err
+		}
+		return baseURL.ResolveReference(fileURL).String(), nil
+	}
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file), nil
+	}
+	return filepath.Join(filepath.Dir(base), file), nil
+}
+
+// load returns the parsed document at the location, a file or url, in yaml or json.
+func (r *refResolver) load(location string) (interface{}, error) {
+	if doc, ok := r.docs[location]; ok {
+		return doc, nil
+	}
+	var data []byte
+	var err error
+	if IsURL(location) {
+		if cached, ok := remoteRefCache[location]; ok {
+			data = cached
+		} else {
+			resp, err := resty.R().Get(location)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode() >= 300 {
+				return nil, fmt.Errorf("got %s", resp.Status())
+			}
+			data = resp.Body()
+			remoteRefCache[location] = data
+		}
+	} else {
+		data, err = ioutil.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+	}
+	jsonBytes, err := mqutil.YamlToJson(data)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(jsonBytes, &doc)
+	if err != nil {
+		return nil, err
+	}
+	r.docs[location] = doc
+	return doc, nil
+}
+
+// localName returns a name in the section of the root document for the ref target, avoiding the names
+// already taken.
+func (r *refResolver) localName(section string, location string, tokens []string) string {
+	name := ""
+	if len(tokens) > 0 {
+		name = tokens[len(tokens)-1]
+	} else {
+		base := filepath.Base(location)
+		if u, err := url.Parse(location); err == nil && IsURL(location) {
+			base = filepath.Base(u.Path)
+		}
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	sectionMap, _ := r.root[section].(map[string]interface{})
+	candidate := name
+	for i := 2; ; i++ {
+		if _, taken := sectionMap[candidate]; !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+}
+
+// resolve returns the local ref for the ref found in the document at base, copying its target into the
+// root document if it's in another document.
+func (r *refResolver) resolve(base string, ref string) (string, error) {
+	file, fragment := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		file, fragment = ref[:i], ref[i+1:]
+	}
+	location := base
+	if len(file) > 0 {
+		var err error
+		location, err = refLocation(base, file)
+		if err != nil {
+			return "", err
+		}
+	}
+	if location == r.rootPath {
+		return "#" + fragment, nil
+	}
+	key := location + "#" + fragment
+	if local, ok := r.resolved[key]; ok {
+		return local, nil
+	}
+
+	doc, err := r.load(location)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := pointerTokens(fragment)
+	if err != nil {
+		return "", err
+	}
+	target, err := getAt(doc, tokens)
+	if err != nil {
+		return "", err
+	}
+	section := "definitions"
+	if len(tokens) == 2 && (tokens[0] == "parameters" || tokens[0] == "responses") {
+		section = tokens[0]
+	}
+	name := r.localName(section, location, tokens)
+	local := "#/" + section + "/" + strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+
+	// Claim the name before going into the target, which may refer back to itself.
+	r.resolved[key] = local
+	if r.root[section] == nil {
+		r.root[section] = make(map[string]interface{})
+	}
+	sectionMap, _ := r.root[section].(map[string]interface{})
+	sectionMap[name] = nil
+
+	// Copy the target, the refs in it are relative to its own document.
+	data, _ := json.Marshal(target)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	sectionMap[name] = r.walk(copied, location)
+	return local, nil
+}
+
+// walk replaces the refs to other documents in the object, which is part of the document at base.
+func (r *refResolver) walk(obj interface{}, base string) interface{} {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if ref, ok := o["$ref"].(string); ok && (base != r.rootPath || !strings.HasPrefix(ref, "#")) {
+			local, err := r.resolve(base, ref)
+			if err != nil {
+				r.unresolved = append(r.unresolved, fmt.Sprintf("%s in %s: %s", ref, base, err.Error()))
+			} else {
+				o["$ref"] = local
+			}
+		}
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if k != "$ref" {
+				o[k] = r.walk(o[k], base)
+			}
+		}
+	case []interface{}:
+		for i, v := range o {
+			o[i] = r.walk(v, base)
+		}
+	}
+	return obj
+}
+
+// ResolveExternalRefs merges the targets of the $refs to other files, relative to the swagger at path,
+// and to remote urls into the json swagger document. Returns an error listing the refs that can't be resolved.
+func ResolveExternalRefs(jsonBytes []byte, path string) ([]byte, error) {
+	var root map[string]interface{}
+	err := json.Unmarshal(jsonBytes, &root)
+	if err != nil {
+		return nil, err
+	}
+	rootPath := path
+	if !IsURL(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			rootPath = abs
+		}
+	}
+	r := &refResolver{
+		root:     root,
+		rootPath: rootPath,
+		docs:     make(map[string]interface{}),
+		resolved: make(map[string]string),
+	}
+	r.walk(root, rootPath)
+	if len(r.unresolved) > 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't resolve the following $refs:\n    %s",
+			strings.Join(r.unresolved, "\n    ")))
+	}
+	if len(r.resolved) == 0 {
+		return jsonBytes, nil
+	}
+	mqutil.Logger.Printf("merged %d external $refs into %s", len(r.resolved), path)
+	return json.Marshal(root)
+}
@@ -0,0 +1,146 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFixtures writes the files under a temp dir, and returns the dir.
+func writeFixtures(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "meqa-refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolveExternalRefs(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"api/defs/pet.json": `{
+  "Pet": {"properties": {"tag": {"$ref": "#/Tag"}, "error": {"$ref": "../../common/error.json"}}},
+  "Tag": {"type": "object"}
+}`,
+		"common/error.json":  `{"type": "object", "properties": {"code": {"type": "integer"}}}`,
+		"api/params.json":    `{"parameters": {"limit": {"name": "limit", "in": "query", "type": "integer"}}}`,
+		"api/defs/node.json": `{"Node": {"properties": {"children": {"type": "array", "items": {"$ref": "#/Node"}}}}}`,
+		"api/defs/a.json":    `{"A": {"properties": {"b": {"$ref": "b.json#/B"}}}}`,
+		"api/defs/b.json":    `{"B": {"properties": {"a": {"$ref": "a.json#/A"}}}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	swagger := `{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"$ref": "params.json#/parameters/limit"}],
+        "responses": {"200": {"schema": {"$ref": "defs/pet.json#/Pet"}}}
+      },
+      "post": {
+        "responses": {"200": {"schema": {"$ref": "./defs/pet.json#/Pet"}}, "default": {"schema": {"$ref": "#/definitions/Local"}}}
+      }
+    },
+    "/nodes": {"get": {"responses": {"200": {"schema": {"$ref": "defs/node.json#/Node"}}}}},
+    "/a": {"get": {"responses": {"200": {"schema": {"$ref": "defs/a.json#/A"}}}}}
+  },
+  "definitions": {"Local": {"type": "object"}, "Tag": {"type": "string"}}
+}`
+	resolved, err := ResolveExternalRefs([]byte(swagger), filepath.Join(dir, "api", "swagger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc, expected map[string]interface{}
+	if err = json.Unmarshal(resolved, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// The same target is merged once, the names taken get a number, and the cycles refer to the merged copy.
+	err = json.Unmarshal([]byte(`{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"$ref": "#/parameters/limit"}],
+        "responses": {"200": {"schema": {"$ref": "#/definitions/Pet"}}}
+      },
+      "post": {
+        "responses": {"200": {"schema": {"$ref": "#/definitions/Pet"}}, "default": {"schema": {"$ref": "#/definitions/Local"}}}
+      }
+    },
+    "/nodes": {"get": {"responses": {"200": {"schema": {"$ref": "#/definitions/Node"}}}}},
+    "/a": {"get": {"responses": {"200": {"schema": {"$ref": "#/definitions/A"}}}}}
+  },
+  "definitions": {
+    "Local": {"type": "object"},
+    "Tag": {"type": "string"},
+    "Pet": {"properties": {"tag": {"$ref": "#/definitions/Tag2"}, "error": {"$ref": "#/definitions/error"}}},
+    "Tag2": {"type": "object"},
+    "error": {"type": "object", "properties": {"code": {"type": "integer"}}},
+    "Node": {"properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/Node"}}}},
+    "A": {"properties": {"b": {"$ref": "#/definitions/B"}}},
+    "B": {"properties": {"a": {"$ref": "#/definitions/A"}}}
+  },
+  "parameters": {"limit": {"name": "limit", "in": "query", "type": "integer"}}
+}`), &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("got the swagger\n%s", resolved)
+	}
+
+	// Without external refs the swagger is left as it is.
+	local := `{"swagger": "2.0", "paths": {}, "definitions": {"A": {"$ref": "#/definitions/B"}, "B": {}}}`
+	resolved, err = ResolveExternalRefs([]byte(local), filepath.Join(dir, "api", "swagger.json"))
+	if err != nil || string(resolved) != local {
+		t.Errorf("got %s, %v for a swagger without external refs", resolved, err)
+	}
+}
+
+func TestResolveExternalRefsErrors(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"pet.json":     `{"Pet": {"type": "object"}}`,
+		"invalid.json": `{"Pet": `,
+	})
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name   string
+		ref    string
+		causes []string
+	}{
+		{"missing file", "missing.json#/Pet", []string{"missing.json#/Pet in ", "missing.json"}},
+		{"missing fragment", "pet.json#/Dog", []string{"pet.json#/Dog in "}},
+		{"invalid file", "invalid.json#/Pet", []string{"invalid.json#/Pet in "}},
+	}
+	for _, c := range cases {
+		swagger := `{"swagger": "2.0", "paths": {"/pets": {"get": {"responses": {"200": {"schema": {"$ref": "` + c.ref + `"}}}}}}}`
+		_, err := ResolveExternalRefs([]byte(swagger), filepath.Join(dir, "swagger.json"))
+		if err == nil {
+			t.Errorf("%s: expecting an error", c.name)
+			continue
+		}
+		for _, cause := range c.causes {
+			if !strings.Contains(err.Error(), cause) {
+				t.Errorf("%s: expecting %q in the error: %s", c.name, cause, err.Error())
+			}
+		}
+	}
+
+	if _, err := ResolveExternalRefs([]byte(`{"swagger": `), filepath.Join(dir, "swagger.json")); err == nil {
+		t.Errorf("expecting an error for invalid json")
+	}
+}
@@ -0,0 +1,90 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"meqa/mqutil"
+)
+
+// SeedFile is the yaml or json file of the objects that exist on the server before the run, by the name
+// of their swagger definition, e.g. {"Account": [{"id": 1, "name": "admin"}]}.
+var SeedFile string
+
+// LoadSeed loads the objects of the seed file into the DB, so that the tests can use them for their
+// parameters like the objects they create. The seed is kept to be loaded again on every Init.
+func (db *DB) LoadSeed(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't read the seed file %s: %s", path, err.Error()))
+	}
+	jsonBytes, err := mqutil.YamlToJson(data)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse the seed file %s: %s", path, err.Error()))
+	}
+	var seeds map[string][]map[string]interface{}
+	err = json.Unmarshal(jsonBytes, &seeds)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"the seed file %s should map definition names to lists of objects: %s", path, err.Error()))
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var names []string
+	for name := range seeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schemaDB := db.schemas[name]
+		if schemaDB == nil {
+			return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("the seed file %s has objects of %s, which is not a definition in the swagger", path, name))
+		}
+		for i, obj := range seeds[name] {
+			if !schemaDB.Schema.Matches(obj, db.Swagger) {
+				mqutil.Warnf("seed object %d of %s doesn't match its schema", i, name)
+			}
+		}
+	}
+	db.seeds = seeds
+	db.insertSeeds()
+	mqutil.Logger.Printf("loaded the objects of %d definitions from %s", len(seeds), path)
+	return nil
+}
+
+// insertSeeds inserts the seed objects, marked as seeded. The caller holds the lock.
+func (db *DB) insertSeeds() {
+	for name, objects := range db.seeds {
+		schemaDB := db.schemas[name]
+		if schemaDB == nil {
+			continue
+		}
+		for _, obj := range objects {
+			if len(obj) == 0 {
+				continue
+			}
+			schemaDB.Objects = append(schemaDB.Objects, &DBEntry{Data: mqutil.MapCopy(obj), Seeded: true})
+		}
+	}
+}
+
+// IsSeeded returns whether the object came from the seed file. These objects are never deleted by the tests.
+func (db *DB) IsSeeded(name string, obj interface{}) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if db.schemas[name] == nil {
+		return false
+	}
+	for _, entry := range db.schemas[name].Objects {
+		if entry.Seeded && mqutil.InterfaceEquals(obj, entry.Data) {
+			return true
+		}
+	}
+	return false
+}
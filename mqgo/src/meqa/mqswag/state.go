@@ -0,0 +1,147 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"meqa/mqutil"
+)
+
+// StateFile is where the objects in the DB are saved at the end of a run, and loaded from at the start of
+// the next one, so that the tests can use the objects created by the earlier runs.
+var StateFile string
+
+// stateObject is an object of the state file, with what the DB knows about it.
+type stateObject struct {
+	Data         map[string]interface{}            `json:"data"`
+	Associations map[string]map[string]interface{} `json:"associations,omitempty"`
+	PathParams   map[string]interface{}            `json:"pathParams,omitempty"`
+}
+
+// LoadState loads the objects of the state file into the DB. A state file that doesn't exist yet is the
+// same as an empty one. Like the seed, the state is loaded again on every Init.
+func (db *DB) LoadState(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		mqutil.Logger.Printf("the state file %s doesn't exist yet, starting without objects", path)
+		return nil
+	}
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't read the state file %s: %s", path, err.Error()))
+	}
+	var state map[string][]*stateObject
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse the state file %s: %s", path, err.Error()))
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.state = make(map[string][]*DBEntry)
+	count := 0
+	for name, objects := range state {
+		if db.schemas[name] == nil {
+			// The definition may be gone from the swagger since the state was saved.
+			mqutil.Warnf("the state file %s has objects of %s, which is not a definition in the swagger", path, name)
+			continue
+		}
+		for _, obj := range objects {
+			if obj == nil || len(obj.Data) == 0 {
+				continue
+			}
+			db.state[name] = append(db.state[name], &DBEntry{Data: obj.Data, Associations: obj.Associations, PathParams: obj.PathParams})
+			count++
+		}
+	}
+	db.insertState()
+	mqutil.Logger.Printf("loaded %d objects from the state file %s", count, path)
+	return nil
+}
+
+// insertState inserts copies of the objects loaded from the state file. The caller holds the lock.
+func (db *DB) insertState() {
+	for name, entries := range db.state {
+		schemaDB := db.schemas[name]
+		if schemaDB == nil {
+			continue
+		}
+		for _, entry := range entries {
+			schemaDB.Objects = append(schemaDB.Objects, &DBEntry{
+				Data:         mqutil.MapCopy(entry.Data),
+				Associations: entry.Associations,
+				PathParams:   mqutil.MapCopy(entry.PathParams),
+			})
+		}
+	}
+}
+
+// StateNames returns the names of the definitions that have objects loaded from the state file, sorted.
+func (db *DB) StateNames() []string {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var names []string
+	for name := range db.state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StateObjects returns the objects of the definition that were loaded from the state file.
+func (db *DB) StateObjects(name string) []*DBEntry {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.state[name]
+}
+
+// DropState removes an object loaded from the state file from the DB, e.g. because the server doesn't
+// have it anymore.
+func (db *DB) DropState(name string, entry *DBEntry) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	var kept []*DBEntry
+	for _, e := range db.state[name] {
+		if e != entry {
+			kept = append(kept, e)
+		}
+	}
+	db.state[name] = kept
+	if schemaDB := db.schemas[name]; schemaDB != nil {
+		schemaDB.Delete(entry.Data, nil, mqutil.InterfaceEquals, 1)
+	}
+}
+
+// SaveState writes the objects in the DB to the state file, by definition name. The seed objects are
+// left out, they are in the seed file.
+func (db *DB) SaveState(path string) error {
+	db.mutex.Lock()
+	state := make(map[string][]*stateObject)
+	count := 0
+	for name, schemaDB := range db.schemas {
+		for _, entry := range schemaDB.Objects {
+			if entry.Seeded {
+				continue
+			}
+			state[name] = append(state[name], &stateObject{entry.Data, entry.Associations, entry.PathParams})
+			count++
+		}
+	}
+	db.mutex.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return err
+	}
+	mqutil.Logger.Printf("saved %d objects to the state file %s", count, path)
+	return nil
+}
@@ -0,0 +1,572 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"meqa/mqutil"
+)
+
+// This is the Go version of the tagger in mqtag/tag.py, used to generate without the meqa server. It adds
+// the same <meqa Class.property> tags to the parameters, properties and operations. The python version
+// relies on word vectors to normalize the words. Here the names are split on case and punctuation and
+// the plurals are dropped, and a post operation's real method is guessed from a list of verbs.
+
+type tagProperty struct {
+	name     string
+	normName string
+	typeName string
+}
+
+type tagDefinition struct {
+	name       string
+	normName   string
+	properties []tagProperty
+}
+
+type tagger struct {
+	doc         map[string]interface{}
+	definitions map[string]*tagDefinition // by the normalized name
+	normNames   []string                  // the sorted keys of definitions, so that the ties break the same way
+}
+
+// The endings of the latin words in us, whose plural is in uses, e.g. statuses and campuses.
+var usPlurals = []string{"tuses", "ruses", "nuses", "buses", "puses"}
+
+// singular drops the plural ending of the word.
+func singular(word string) string {
+	for _, suffix := range usPlurals {
+		if strings.HasSuffix(word, suffix) {
+			return word[:len(word)-2]
+		}
+	}
+	switch {
+	case word == "ids":
+		return "id"
+	case strings.HasSuffix(word, "vies"):
+		// E.g. movies.
+		return word[:len(word)-1]
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 4 && (strings.HasSuffix(word, "sses") || strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes")):
+		return word[:len(word)-2]
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// normalizeWords splits the name into lower case words, e.g. petId, pet_id and PetID all become "pet id".
+func normalizeWords(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			w := strings.ToLower(string(word))
+			if w != "id" {
+				w = singular(w)
+			}
+			words = append(words, w)
+			word = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) {
+			// Like the python version we only keep the alphabetical words.
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			// The last capital of an acronym starts the next word, as in HTTPServer, but not the plural
+			// of the acronym, as in userIDs.
+			nextWord := i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+				!(runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2])))
+			if unicode.IsLower(prev) || (nextWord && unicode.IsUpper(prev)) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return strings.Join(words, " ")
+}
+
+func wordSet(phrase string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(phrase) {
+		set[w] = true
+	}
+	return set
+}
+
+// matchPhrase tries to find the key in the phrase, both normalized. Returns -1 if not found, 0 if it's an
+// exact match, and the distance between the words otherwise.
+func matchPhrase(phrase string, key string) float64 {
+	phraseList := strings.Fields(phrase)
+	keyList := strings.Fields(key)
+	if len(keyList) == 0 {
+		return -1
+	}
+	phraseSet := wordSet(phrase)
+	keySet := wordSet(key)
+	for w := range keySet {
+		if !phraseSet[w] {
+			return -1
+		}
+	}
+	if phrase == key {
+		return 0
+	}
+	overhead := float64(len(phraseSet)-len(keySet)) / float64(len(phraseSet))
+	if strings.Contains(phrase, key) {
+		return overhead
+	}
+
+	// Find the shortest span of the phrase that has all the words of the key.
+	minSpan := len(phraseList)
+	lastKey := make(map[int]int) // the key index to its last position in the phrase
+	for i, p := range phraseList {
+		for k, w := range keyList {
+			if p != w {
+				continue
+			}
+			lastKey[k] = i
+			if len(lastKey) == len(keySet) {
+				first, last := i, i
+				for _, pos := range lastKey {
+					if pos < first {
+						first = pos
+					}
+					if pos > last {
+						last = pos
+					}
+				}
+				if last-first < minSpan {
+					minSpan = last - first
+				}
+			}
+			break
+		}
+	}
+	return float64(minSpan+1-len(keySet)) + overhead
+}
+
+func schemaMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// iterateSchema calls the callback for the schema and the schemas under it. The path is the list of keys
+// leading to the schema from the swagger root, e.g. [definitions, Pet, id].
+func (tg *tagger) iterateSchema(schema map[string]interface{}, callback func(map[string]interface{}, []string),
+	path []string, followArray bool, followRef bool, followObject bool) {
+
+	if schema == nil {
+		return
+	}
+	callback(schema, path)
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			tg.iterateSchema(schemaMap(s), callback, path, followArray, followRef, followObject)
+		}
+		return
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		if !followRef || !strings.HasPrefix(ref, "#") {
+			return
+		}
+		refList := strings.Split(ref, "/")
+		if len(refList) != 3 {
+			return
+		}
+		refSchema := schemaMap(schemaMap(tg.doc[refList[1]])[refList[2]])
+		tg.iterateSchema(refSchema, callback, refList[1:3], followArray, followRef, followObject)
+		return
+	}
+	switch schema["type"] {
+	case gojsonschema.TYPE_ARRAY:
+		if followArray {
+			tg.iterateSchema(schemaMap(schema["items"]), callback, path, followArray, followRef, followObject)
+		}
+	case gojsonschema.TYPE_OBJECT:
+		if !followObject {
+			return
+		}
+		properties := schemaMap(schema["properties"])
+		for _, k := range sortedKeys(properties) {
+			propertyPath := append(append([]string{}, path...), k)
+			tg.iterateSchema(schemaMap(properties[k]), callback, propertyPath, followArray, followRef, followObject)
+		}
+	}
+}
+
+// getProperties returns the properties of the object schema, including those of the schemas it refers to.
+func (tg *tagger) getProperties(schema map[string]interface{}) []tagProperty {
+	var properties []tagProperty
+	tg.iterateSchema(schema, func(s map[string]interface{}, path []string) {
+		props := schemaMap(s["properties"])
+		for _, name := range sortedKeys(props) {
+			typeName, _ := schemaMap(props[name])["type"].(string)
+			properties = append(properties, tagProperty{name, normalizeWords(name), typeName})
+		}
+	}, nil, false, true, false)
+	return properties
+}
+
+// gatherDefinitions collects the object definitions of the swagger.
+func (tg *tagger) gatherDefinitions() {
+	tg.definitions = make(map[string]*tagDefinition)
+	definitions := schemaMap(tg.doc["definitions"])
+	for _, name := range sortedKeys(definitions) {
+		d := &tagDefinition{name: name, normName: normalizeWords(name)}
+		if len(d.normName) == 0 {
+			continue
+		}
+		d.properties = tg.getProperties(schemaMap(definitions[name]))
+		if _, ok := tg.definitions[d.normName]; !ok {
+			tg.normNames = append(tg.normNames, d.normName)
+		}
+		tg.definitions[d.normName] = d
+	}
+	sort.Strings(tg.normNames)
+}
+
+// findDefinition returns the definition the object schema refers to, or the smallest one that has all
+// of the schema's properties.
+func (tg *tagger) findDefinition(schema map[string]interface{}) string {
+	if ref, ok := schema["$ref"].(string); ok && strings.HasPrefix(ref, "#") {
+		refList := strings.Split(ref, "/")
+		return refList[len(refList)-1]
+	}
+	properties := tg.getProperties(schema)
+	if len(properties) <= 2 {
+		// We only match when there are 3 or more fields.
+		return ""
+	}
+	found := ""
+	minProperties := -1
+	for _, normName := range tg.normNames {
+		obj := tg.definitions[normName]
+		objProperties := make(map[string]bool)
+		for _, p := range obj.properties {
+			objProperties[p.name] = true
+		}
+		all := true
+		for _, p := range properties {
+			if !objProperties[p.name] {
+				all = false
+				break
+			}
+		}
+		if all && (minProperties < 0 || len(objProperties) < minProperties) {
+			found = obj.name
+			minProperties = len(objProperties)
+		}
+	}
+	return found
+}
+
+func isNumberType(typeName string) bool {
+	return typeName == gojsonschema.TYPE_INTEGER || typeName == gojsonschema.TYPE_NUMBER
+}
+
+// findObjProperty returns the definition and property that match the phrases. An empty propertyType
+// matches the properties of all types but objects.
+func (tg *tagger) findObjProperty(phrase string, propertyPhrase string, propertyType string, exclude string) (string, string, float64) {
+	// When the object name and the property share the phrase, we try to limit the words reused between the two.
+	var propertyWords map[string]bool
+	if len(propertyPhrase) == 0 {
+		propertyPhrase = phrase
+		propertyWords = wordSet(phrase)
+	}
+	minCost := float64(len(phrase) + len(propertyPhrase))
+	var minObj *tagDefinition
+	minProperty := ""
+
+	for _, normName := range tg.normNames {
+		obj := tg.definitions[normName]
+		if obj.name == exclude {
+			continue
+		}
+		cost := matchPhrase(phrase, normName)
+		if cost < 0 {
+			continue
+		}
+		objPropertyWords := make(map[string]bool)
+		for w := range wordSet(normName) {
+			if propertyWords[w] {
+				objPropertyWords[w] = true
+			}
+		}
+		minPropertyCost := float64(len(propertyPhrase))
+		objMinProperty := ""
+		for _, prop := range obj.properties {
+			if len(propertyType) == 0 {
+				if prop.typeName == gojsonschema.TYPE_OBJECT {
+					continue
+				}
+			} else if propertyType != prop.typeName && !(isNumberType(propertyType) && isNumberType(prop.typeName)) {
+				continue
+			}
+
+			propertyCost := 0.0
+			if propertyWords != nil {
+				words := wordSet(prop.normName)
+				subset := len(words) < len(objPropertyWords)
+				for w := range words {
+					if !objPropertyWords[w] {
+						subset = false
+					}
+				}
+				if !subset {
+					propertyCost = 1
+				}
+			}
+			if propertyPhrase != prop.name && propertyPhrase != prop.normName {
+				propertyCost = matchPhrase(propertyPhrase, prop.normName)
+				if propertyCost < 0 {
+					continue
+				}
+			}
+			if propertyCost < minPropertyCost {
+				minPropertyCost = propertyCost
+				objMinProperty = prop.name
+				if minPropertyCost == 0 {
+					break
+				}
+			}
+		}
+		if len(objMinProperty) == 0 {
+			continue
+		}
+
+		cost += minPropertyCost
+		if cost < minCost {
+			minCost = cost
+			minObj = obj
+			minProperty = objMinProperty
+			if minCost == 0 {
+				break
+			}
+		}
+	}
+	if minObj == nil {
+		return "", "", -1
+	}
+	return minObj.name, minProperty, minCost
+}
+
+func appendTag(m map[string]interface{}, tag string) {
+	desc, _ := m["description"].(string)
+	if len(desc) > 0 {
+		desc += " "
+	}
+	m["description"] = desc + tag
+}
+
+// tryAddTag adds the tag of the matching Class.property to the schema or parameter. Returns whether one is found.
+func (tg *tagger) tryAddTag(phrase string, propertyPhrase string, m map[string]interface{}, propertyType string, exclude string) bool {
+	class, property, _ := tg.findObjProperty(phrase, propertyPhrase, propertyType, exclude)
+	if len(class) > 0 && len(property) > 0 {
+		appendTag(m, (&MeqaTag{Class: class, Property: property}).ToString())
+		return true
+	}
+	return false
+}
+
+func shouldTryTag(m map[string]interface{}) bool {
+	desc, _ := m["description"].(string)
+	return m["enum"] == nil && !meqaTagRegex.MatchString(desc)
+}
+
+// tagParam tags the parameter of the operation on the path.
+func (tg *tagger) tagParam(path string, param map[string]interface{}) {
+	if !shouldTryTag(param) {
+		return
+	}
+	name, _ := param["name"].(string)
+	in, _ := param["in"].(string)
+	typeMatch := ""
+	if in != "path" {
+		typeMatch, _ = param["type"].(string)
+	}
+
+	if in == "path" {
+		// The word right before the parameter is usually the class name, we prefer that.
+		if index := strings.Index(path, "{"+name+"}"); index > 1 {
+			if tg.tryAddTag(normalizeWords(path[:index-1]), name, param, typeMatch, "") {
+				return
+			}
+		}
+	}
+
+	// For the body we go down into the schema instead.
+	if schema := schemaMap(param["schema"]); schema != nil {
+		if found := tg.findDefinition(schema); len(found) > 0 {
+			appendTag(param, (&MeqaTag{Class: found}).ToString())
+		}
+		tg.tagSchemaProperties(schema, []string{name}, "", "")
+		return
+	}
+	if tg.tryAddTag(normalizeWords(name), "", param, typeMatch, "") {
+		return
+	}
+
+	// Try the sentences of the description, e.g. "Updated name of the pet".
+	desc, _ := param["description"].(string)
+	minCost := -1.0
+	var minClass, minProperty string
+	for _, sentence := range strings.Split(desc, ".") {
+		class, property, cost := tg.findObjProperty(normalizeWords(sentence), "", typeMatch, "")
+		if cost >= 0 && (minCost < 0 || cost < minCost) {
+			minCost, minClass, minProperty = cost, class, property
+		}
+	}
+	if minCost > 0 {
+		appendTag(param, (&MeqaTag{Class: minClass, Property: minProperty}).ToString())
+	}
+}
+
+// tagSchemaProperties tags the properties of the object schema by their names. We have to be more careful
+// with these, mistakes lead to cycles in the dependency graph.
+func (tg *tagger) tagSchemaProperties(schema map[string]interface{}, path []string, possibleClass string, exclude string) {
+	tg.iterateSchema(schema, func(s map[string]interface{}, p []string) {
+		if !shouldTryTag(s) || len(p) == 0 {
+			return
+		}
+		typeName, _ := s["type"].(string)
+		if typeName != gojsonschema.TYPE_INTEGER && typeName != gojsonschema.TYPE_NUMBER && typeName != gojsonschema.TYPE_STRING {
+			return
+		}
+		normName := normalizeWords(p[len(p)-1])
+		if tg.tryAddTag(normName, "", s, typeName, exclude) {
+			return
+		}
+		if len(possibleClass) > 0 {
+			tg.tryAddTag(possibleClass, normName, s, typeName, exclude)
+		}
+	}, path, true, false, true)
+}
+
+// The words that tell what a post operation actually does.
+var methodWords = map[string]string{
+	"create": MethodPost, "add": MethodPost, "new": MethodPost, "insert": MethodPost,
+	"update": MethodPut, "modify": MethodPut, "change": MethodPut, "edit": MethodPut, "replace": MethodPut,
+	"delete": MethodDelete, "remove": MethodDelete, "erase": MethodDelete,
+	"get": MethodGet, "retrieve": MethodGet, "find": MethodGet, "fetch": MethodGet, "list": MethodGet,
+	"search": MethodGet, "query": MethodGet, "read": MethodGet,
+}
+
+func guessMethod(desc string) string {
+	for _, w := range strings.Fields(normalizeWords(desc)) {
+		if method, ok := methodWords[w]; ok {
+			return method
+		}
+	}
+	return ""
+}
+
+func (tg *tagger) addTags() {
+	paths := schemaMap(tg.doc["paths"])
+	for _, pathName := range sortedKeys(paths) {
+		pathItem := schemaMap(paths[pathName])
+		if pathItem == nil {
+			continue
+		}
+		// We make a guess at what the operations are about from the last fixed part of the path, and put
+		// the tag in the operation's description. mqgo only uses it as one of the last resorts.
+		var pathClass *tagDefinition
+		entries := strings.Split(pathName, "/")
+		for i := len(entries) - 1; i >= 0; i-- {
+			if len(entries[i]) > 0 && entries[i][0] != '{' {
+				pathClass = tg.definitions[normalizeWords(entries[i])]
+				break
+			}
+		}
+
+		tagParams := func(params interface{}) {
+			list, _ := params.([]interface{})
+			for _, p := range list {
+				if param := schemaMap(p); param != nil {
+					tg.tagParam(pathName, param)
+				}
+			}
+		}
+		tagParams(pathItem["parameters"])
+		for _, method := range MethodAll {
+			op := schemaMap(pathItem[method])
+			if op == nil {
+				continue
+			}
+			tagParams(op["parameters"])
+			responses := schemaMap(op["responses"])
+			for _, code := range sortedKeys(responses) {
+				possibleClass := ""
+				if status, err := strconv.Atoi(code); err == nil && status >= 200 && status < 300 && pathClass != nil {
+					possibleClass = pathClass.normName
+				}
+				tg.tagSchemaProperties(schemaMap(schemaMap(responses[code])["schema"]), []string{code}, possibleClass, "")
+			}
+
+			if pathClass != nil && shouldTryTag(op) {
+				tag := "<meqa " + pathClass.name + ">"
+				if method == MethodPost {
+					desc, _ := op["description"].(string)
+					summary, _ := op["summary"].(string)
+					if guessed := guessMethod(desc + " " + summary); len(guessed) > 0 && guessed != method {
+						// The operation goes after an empty property.
+						tag = "<meqa " + pathClass.name + ".." + guessed + ">"
+					}
+				}
+				appendTag(op, tag)
+			}
+		}
+	}
+
+	definitions := schemaMap(tg.doc["definitions"])
+	for _, name := range sortedKeys(definitions) {
+		tg.tagSchemaProperties(schemaMap(definitions[name]), []string{"definitions", name}, "", name)
+	}
+}
+
+// TagSwagger adds the meqa tags to the swagger spec, in json or yaml, and returns the tagged spec in yaml.
+func TagSwagger(swaggerBytes []byte) ([]byte, error) {
+	jsonBytes, err := mqutil.YamlToJson(swaggerBytes)
+	if err != nil {
+		return nil, err
+	}
+	tg := &tagger{}
+	err = json.Unmarshal(jsonBytes, &tg.doc)
+	if err != nil {
+		return nil, err
+	}
+	if tg.doc["swagger"] != "2.0" {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"we only support swagger/openapi spec 2.0 right now. Your version is %v", tg.doc["swagger"]))
+	}
+	tg.gatherDefinitions()
+	tg.addTags()
+	jsonBytes, err = json.Marshal(tg.doc)
+	if err != nil {
+		return nil, err
+	}
+	return mqutil.JsonToYaml(jsonBytes)
+}
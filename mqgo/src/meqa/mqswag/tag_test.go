@@ -0,0 +1,124 @@
+package mqswag
+
+import (
+	"testing"
+)
+
+func TestSingular(t *testing.T) {
+	cases := []struct {
+		word     string
+		singular string
+	}{
+		{"pets", "pet"},
+		{"pet", "pet"},
+		{"categories", "category"},
+		{"addresses", "address"},
+		{"address", "address"},
+		{"statuses", "status"},
+		{"status", "status"},
+		{"buses", "bus"},
+		{"campuses", "campus"},
+		{"bonuses", "bonus"},
+		{"houses", "house"},
+		{"causes", "cause"},
+		{"movies", "movie"},
+		{"boxes", "box"},
+		{"matches", "match"},
+		{"wishes", "wish"},
+		{"keys", "key"},
+		{"ids", "id"},
+		{"data", "data"},
+		{"analysis", "analysis"},
+		{"gas", "gas"},
+		{"ties", "tie"},
+		{"s", "s"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if s := singular(c.word); s != c.singular {
+			t.Errorf("singular(%s) = %s, expecting %s", c.word, s, c.singular)
+		}
+	}
+}
+
+func TestNormalizeWords(t *testing.T) {
+	cases := []struct {
+		name  string
+		words string
+	}{
+		{"petId", "pet id"},
+		{"pet_id", "pet id"},
+		{"PetID", "pet id"},
+		{"pet-id", "pet id"},
+		{"pet.id", "pet id"},
+		{"pet2Id", "pet id"},
+		{"petIds", "pet id"},
+		{"userIDs", "user id"},
+		{"petIDsByOwner", "pet id by owner"},
+		{"HTTPServer", "http server"},
+		{"XMLHttpRequest", "xml http request"},
+		{"orderStatuses", "order status"},
+		{"shipping_addresses", "shipping address"},
+		{"Categories", "category"},
+		{"data", "data"},
+		{"metaData", "meta data"},
+		{"ID", "id"},
+		{"/store/{orderId}", "store order id"},
+		{"  Creates a NEW pet ", "create a new pet"},
+		{"123", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if words := normalizeWords(c.name); words != c.words {
+			t.Errorf("normalizeWords(%q) = %q, expecting %q", c.name, words, c.words)
+		}
+	}
+}
+
+func TestMatchPhrase(t *testing.T) {
+	cases := []struct {
+		phrase string
+		key    string
+		match  float64
+	}{
+		{"pet id", "pet id", 0},
+		{"pet", "pet", 0},
+		{"pet id", "user", -1},
+		{"pet id", "pet name", -1},
+		{"pet id", "", -1},
+		{"", "pet", -1},
+		{"owner pet id", "pet id", 1.0 / 3},
+		{"pet id owner", "pet", 2.0 / 3},
+		{"pet owner id", "pet id", 1 + 1.0/3},
+		{"pet owner name id", "pet id", 2 + 2.0/4},
+		{"id owner pet id", "pet id", 1.0 / 3},
+		{"pet pet id", "pet id", 0},
+		{"id pet", "pet id", 0},
+	}
+	for _, c := range cases {
+		if match := matchPhrase(c.phrase, c.key); match < c.match-1e-9 || match > c.match+1e-9 {
+			t.Errorf("matchPhrase(%q, %q) = %v, expecting %v", c.phrase, c.key, match, c.match)
+		}
+	}
+}
+
+func TestGuessMethod(t *testing.T) {
+	cases := []struct {
+		desc   string
+		method string
+	}{
+		{"Creates a new pet in the store", MethodPost},
+		{"Add a new pet", MethodPost},
+		{"Updates a pet with form data", MethodPut},
+		{"Removes the pet", MethodDelete},
+		{"Lists the pets", MethodGet},
+		{"findPetsByStatus", MethodGet},
+		{"Place an order for a pet", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if method := guessMethod(c.desc); method != c.method {
+			t.Errorf("guessMethod(%q) = %q, expecting %q", c.desc, method, c.method)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	openapierrors "github.com/go-openapi/errors"
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+
+	"meqa/mqutil"
+)
+
+// Problem is an issue of a swagger spec found by ValidateSwagger.
+type Problem struct {
+	Path    []string // the keys leading to the problem in the swagger, e.g. paths, /pets, get
+	Line    int      // the line of the problem in the swagger, 0 when it isn't known
+	Message string
+	Warning bool // meqa can work around it, generate stops on the other problems
+	Fixed   bool // corrected in the swagger returned by ValidateSwagger
+}
+
+func (p *Problem) String() string {
+	s := ""
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Warning {
+		s += "warning: "
+	} else {
+		s += "error: "
+	}
+	if len(p.Path) > 0 {
+		s += strings.Join(p.Path, ".") + ": "
+	}
+	return s + p.Message
+}
+
+// The parameter types of swagger 2.0 other than body parameters.
+var parameterTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true, "array": true, "file": true,
+}
+
+// ValidateSwagger checks the swagger, in json or yaml, against the swagger 2.0 schema, and for what meqa
+// needs to generate tests from it: that the operations have a unique operationId, that the $refs point
+// to something and that the parameters have a type. The operations without an operationId, or with one
+// that is already used, get one from their method and path, and the parameters without a type are
+// strings. Those problems are warnings, the others are errors. Returns the swagger with the problems
+// fixed, as json, or unchanged when nothing was fixed. The relative external $refs are resolved against
+// path.
+func ValidateSwagger(swaggerBytes []byte, path string) ([]byte, []*Problem, error) {
+	jsonBytes := swaggerBytes
+	var err error
+	if !looksLikeJSON(swaggerBytes) {
+		jsonBytes, err = mqutil.YamlToJson(swaggerBytes)
+		if err != nil {
+			return nil, nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("the swagger %s isn't valid json or yaml: %s", path, err.Error()))
+		}
+	}
+	var doc map[string]interface{}
+	if err = json.Unmarshal(jsonBytes, &doc); err != nil {
+		return nil, nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("the swagger %s isn't a json or yaml object: %s", path, err.Error()))
+	}
+
+	v := &specValidator{doc: doc}
+	if version, _ := doc["swagger"].(string); version != "2.0" {
+		v.problem(false, false, "only swagger 2.0 is supported", "swagger")
+	} else {
+		v.checkOperations()
+		v.checkRefs(doc, nil)
+	}
+
+	result := swaggerBytes
+	if v.fixed {
+		if result, err = json.Marshal(doc); err != nil {
+			return nil, nil, err
+		}
+	}
+	// The schema is only checked once the swagger can be loaded, the errors above would be reported again.
+	if v.errors == 0 {
+		if v.fixed {
+			jsonBytes = result
+		}
+		resolvedBytes, err := ResolveExternalRefs(jsonBytes, path)
+		if err != nil {
+			v.problem(false, false, err.Error())
+		} else {
+			v.checkSchema(resolvedBytes)
+		}
+	}
+
+	lines := strings.Split(string(swaggerBytes), "\n")
+	for _, p := range v.problems {
+		if len(p.Path) > 0 {
+			p.Line = lineOf(lines, p.Path)
+		}
+	}
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].Line < v.problems[j].Line
+	})
+	return result, v.problems, nil
+}
+
+// specValidator collects the problems of a swagger document.
+type specValidator struct {
+	doc      map[string]interface{}
+	problems []*Problem
+	errors   int
+	fixed    bool
+}
+
+func (v *specValidator) problem(warning bool, fixed bool, message string, path ...string) {
+	v.problems = append(v.problems, &Problem{Path: path, Message: message, Warning: warning, Fixed: fixed})
+	if !warning {
+		v.errors++
+	}
+	if fixed {
+		v.fixed = true
+	}
+}
+
+// checkOperations assigns the missing and duplicate operationIds, and checks the parameters of the
+// operations and of the path items.
+func (v *specValidator) checkOperations() {
+	paths, _ := v.doc["paths"].(map[string]interface{})
+	// In the order of the swagger, so that the same operations keep their id and those that get one
+	// always get the same.
+	var pathNames []string
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+
+	used := make(map[string]bool)
+	for _, path := range pathNames {
+		pathItem, _ := paths[path].(map[string]interface{})
+		for _, method := range operationMethods {
+			if op, ok := pathItem[method].(map[string]interface{}); ok {
+				if id, _ := op["operationId"].(string); len(id) > 0 {
+					used[id] = true
+				}
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, path := range pathNames {
+		pathItem, ok := paths[path].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		v.checkParameters(pathItem["parameters"], "paths", path, "parameters")
+		for _, method := range operationMethods {
+			op, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := op["operationId"].(string)
+			if len(id) == 0 || seen[id] {
+				newID := uniqueName(operationName(method, path), used)
+				used[newID] = true
+				if len(id) == 0 {
+					v.problem(true, true, fmt.Sprintf("no operationId, using %s", newID), "paths", path, method)
+				} else {
+					v.problem(true, true, fmt.Sprintf("operationId %s is already used, using %s", id, newID), "paths", path, method, "operationId")
+				}
+				op["operationId"] = newID
+				id = newID
+			}
+			seen[id] = true
+			v.checkParameters(op["parameters"], "paths", path, method, "parameters")
+		}
+	}
+	if parameters, ok := v.doc["parameters"].(map[string]interface{}); ok {
+		for name, param := range parameters {
+			v.checkParameter(param, "parameters", name)
+		}
+	}
+}
+
+func (v *specValidator) checkParameters(parameters interface{}, path ...string) {
+	list, _ := parameters.([]interface{})
+	for i, param := range list {
+		v.checkParameter(param, append(path, strconv.Itoa(i))...)
+	}
+}
+
+// checkParameter checks that the parameter has a type meqa can generate a value of. The ones that are
+// a $ref are checked where they are defined.
+func (v *specValidator) checkParameter(parameter interface{}, path ...string) {
+	param, ok := parameter.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if _, ok := param["$ref"]; ok {
+		return
+	}
+	name, _ := param["name"].(string)
+	if in, _ := param["in"].(string); in == "body" {
+		if _, ok := param["schema"].(map[string]interface{}); !ok {
+			v.problem(false, false, fmt.Sprintf("body parameter %s has no schema", name), path...)
+		}
+		return
+	}
+	paramType, ok := param["type"].(string)
+	if !ok {
+		param["type"] = "string"
+		v.problem(true, true, fmt.Sprintf("parameter %s has no type, using string", name), path...)
+		return
+	}
+	if !parameterTypes[paramType] {
+		v.problem(false, false, fmt.Sprintf("parameter %s has the unknown type %s", name, paramType), append(path, "type")...)
+		return
+	}
+	if _, ok := param["items"].(map[string]interface{}); paramType == "array" && !ok {
+		param["items"] = map[string]interface{}{"type": "string"}
+		v.problem(true, true, fmt.Sprintf("array parameter %s has no items, using strings", name), path...)
+	}
+}
+
+// checkRefs reports the local $refs that don't point to anything in the document.
+func (v *specValidator) checkRefs(obj interface{}, path []string) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		if ref, ok := o["$ref"].(string); ok && strings.HasPrefix(ref, "#/") && !v.resolves(ref) {
+			v.problem(false, false, fmt.Sprintf("%s doesn't exist", ref), append(path, "$ref")...)
+		}
+		for k, value := range o {
+			// The examples are data, their $ref keys aren't refs.
+			if k != "example" && k != "examples" && k != "x-example" {
+				v.checkRefs(value, append(path[:len(path):len(path)], k))
+			}
+		}
+	case []interface{}:
+		for i, value := range o {
+			v.checkRefs(value, append(path[:len(path):len(path)], strconv.Itoa(i)))
+		}
+	}
+}
+
+// resolves returns whether the json pointer of the local ref points to something in the document.
+func (v *specValidator) resolves(ref string) bool {
+	var obj interface{} = v.doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if obj, ok = o[token]; !ok {
+				return false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(o) {
+				return false
+			}
+			obj = o[i]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checkSchema validates the json swagger against the swagger 2.0 schema, and its semantics, e.g. that
+// the path parameters are declared.
+func (v *specValidator) checkSchema(jsonBytes []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			v.problem(false, false, fmt.Sprintf("can't validate the swagger: %v", r))
+		}
+	}()
+	specDoc, err := loads.Analyzed(json.RawMessage(jsonBytes), "")
+	if err != nil {
+		v.problem(false, false, fmt.Sprintf("can't load the swagger: %s", err.Error()))
+		return
+	}
+	v.addSchemaErrors(validate.Spec(specDoc, strfmt.Default))
+}
+
+func (v *specValidator) addSchemaErrors(err error) {
+	switch e := err.(type) {
+	case nil:
+	case *openapierrors.CompositeError:
+		for _, err := range e.Errors {
+			v.addSchemaErrors(err)
+		}
+	case *openapierrors.Validation:
+		v.problem(false, false, e.Error(), validationPath(e.Name)...)
+	default:
+		v.problem(false, false, e.Error())
+	}
+}
+
+// validationPath splits the name of a validation error, as in paths./pets.get.parameters, into keys.
+// The path keys can have dots in them, they end at the next method or parameters key.
+func validationPath(name string) []string {
+	tokens := strings.Split(name, ".")
+	if len(tokens) < 3 || tokens[0] != "paths" {
+		return tokens
+	}
+	end := 2
+	for end < len(tokens) && tokens[end] != "parameters" && !isOperationMethod(tokens[end]) {
+		end++
+	}
+	if end == len(tokens) {
+		return tokens
+	}
+	return append([]string{"paths", strings.Join(tokens[1:end], ".")}, tokens[end:]...)
+}
+
+func isOperationMethod(key string) bool {
+	for _, method := range operationMethods {
+		if key == method {
+			return true
+		}
+	}
+	return false
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// operationName returns the operationId of an operation from its method and path, as in getPetsPetId
+// for get /pets/{pet_id}.
+func operationName(method string, path string) string {
+	name := method
+	for _, word := range nonAlphanumeric.Split(path, -1) {
+		if len(word) > 0 {
+			name += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return name
+}
+
+// uniqueName returns the name, followed by a number if it's already used.
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	return unique
+}
+
+// lineOf returns the line of the key at the end of the path in the swagger text, json or yaml, 0 when
+// it can't be found. Each key is looked for among the lines indented under the key before it, and the
+// array indexes count the items there, so it works on indented documents, as most swaggers are, not on
+// json on a single line.
+func lineOf(lines []string, path []string) int {
+	line, parentIndent := -1, -1
+	from := 0
+	for _, key := range path {
+		index, err := strconv.Atoi(key)
+		isIndex := err == nil
+		keyRegex := regexp.MustCompile(`^\s*(-\s+)?["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+		match, item, itemIndent, keyMatch := -1, 0, -1, false
+		for i := from; i < len(lines) && match < 0; i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " \t"))
+			listItem := strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "{")
+			// A yaml list can be at the indentation of its key.
+			if i > from && (indent < parentIndent || indent == parentIndent && !(isIndex && listItem)) {
+				break
+			}
+			// A status code of the responses is a key, not an index.
+			if keyRegex.MatchString(lines[i]) {
+				match, keyMatch = i, true
+				continue
+			}
+			if !isIndex {
+				continue
+			}
+			if !listItem || itemIndent >= 0 && indent != itemIndent {
+				continue
+			}
+			itemIndent = indent
+			if item == index {
+				match = i
+			}
+			item++
+		}
+		if match < 0 {
+			break
+		}
+		line = match
+		parentIndent = len(lines[match]) - len(strings.TrimLeft(lines[match], " \t"))
+		from = match + 1
+		if isIndex && !keyMatch {
+			// The first key of a yaml list item is on the line of the dash.
+			from = match
+		}
+	}
+	return line + 1
+}
@@ -0,0 +1,273 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lineYaml = `swagger: "2.0"
+paths:
+  /pets:
+    parameters:
+    - name: a
+      in: query
+    - name: b
+      in: query
+    get:
+      parameters:
+        - name: x
+          in: query
+        - name: y
+          in: body
+      responses:
+        "200":
+          description: ok
+  /pets/{id}:
+    # the same keys again
+    get:
+      responses:
+        "200":
+          description: ok
+definitions:
+  Pet:
+    type: object
+`
+
+const lineJSON = `{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [
+          {
+            "name": "x",
+            "in": "query"
+          },
+          {
+            "name": "y",
+            "in": "body"
+          }
+        ]
+      }
+    }
+  }
+}`
+
+func TestLineOf(t *testing.T) {
+	cases := []struct {
+		text string
+		path string
+		line int
+	}{
+		{lineYaml, "swagger", 1},
+		{lineYaml, "paths./pets", 3},
+		{lineYaml, "paths./pets.parameters.0", 5},
+		{lineYaml, "paths./pets.parameters.1", 7},
+		{lineYaml, "paths./pets.parameters.1.in", 8},
+		{lineYaml, "paths./pets.get", 9},
+		{lineYaml, "paths./pets.get.parameters.1", 13},
+		{lineYaml, "paths./pets.get.parameters.1.in", 14},
+		{lineYaml, "paths./pets.get.responses.200", 16},
+		{lineYaml, "paths./pets/{id}.get", 20},
+		{lineYaml, "paths./pets/{id}.get.responses.200.description", 23},
+		{lineYaml, "definitions.Pet.type", 26},
+		{lineYaml, "paths./stores", 2},
+		{lineYaml, "paths./pets.parameters.2", 4},
+		{lineYaml, "paths./pets.post", 3},
+		{lineYaml, "info.title", 0},
+		{lineJSON, "paths./pets.get", 5},
+		{lineJSON, "paths./pets.get.parameters.0.name", 8},
+		{lineJSON, "paths./pets.get.parameters.1", 11},
+		{lineJSON, "paths./pets.get.parameters.1.in", 13},
+		{`{"swagger": "2.0", "paths": {}}`, "paths", 0},
+	}
+	for _, c := range cases {
+		lines := strings.Split(c.text, "\n")
+		if line := lineOf(lines, strings.Split(c.path, ".")); line != c.line {
+			t.Errorf("lineOf(%s) = %d, expecting %d", c.path, line, c.line)
+		}
+	}
+}
+
+func TestValidationPath(t *testing.T) {
+	cases := []struct {
+		name string
+		path []string
+	}{
+		{"info.title", []string{"info", "title"}},
+		{"paths./pets.get.parameters", []string{"paths", "/pets", "get", "parameters"}},
+		{"paths./pets.json.get.responses", []string{"paths", "/pets.json", "get", "responses"}},
+		{"paths./v1.0/pets.parameters", []string{"paths", "/v1.0/pets", "parameters"}},
+		{"paths./pets", []string{"paths", "/pets"}},
+		{"paths./pets.json", []string{"paths", "/pets", "json"}},
+	}
+	for _, c := range cases {
+		if path := validationPath(c.name); !reflect.DeepEqual(path, c.path) {
+			t.Errorf("validationPath(%s) = %q, expecting %q", c.name, path, c.path)
+		}
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		name   string
+	}{
+		{"get", "/pets", "getPets"},
+		{"get", "/pets/{pet_id}", "getPetsPetId"},
+		{"delete", "/stores/{storeId}/pets/{petId}", "deleteStoresStoreIdPetsPetId"},
+		{"post", "/v1.0/pets", "postV10Pets"},
+		{"get", "/", "get"},
+	}
+	for _, c := range cases {
+		if name := operationName(c.method, c.path); name != c.name {
+			t.Errorf("operationName(%s, %s) = %s, expecting %s", c.method, c.path, name, c.name)
+		}
+	}
+
+	used := map[string]bool{"getPets": true, "getPets2": true}
+	if name := uniqueName("getPets", used); name != "getPets3" {
+		t.Errorf("uniqueName = %s, expecting getPets3", name)
+	}
+	if name := uniqueName("postPets", used); name != "postPets" {
+		t.Errorf("uniqueName = %s, expecting postPets", name)
+	}
+}
+
+const validateSwagger = `{
+  "swagger": "2.0",
+  "info": {"title": "pets", "version": "1"},
+  "paths": {
+    "/pets/{petId}": {
+      "get": {
+        "parameters": [
+          {
+            "name": "petId",
+            "in": "path",
+            "required": true
+          },
+          {
+            "name": "tags",
+            "in": "query",
+            "type": "array"
+          }
+        ],
+        "responses": {"200": {"description": "ok"}}
+      },
+      "delete": {
+        "operationId": "getPetsPetId",
+        "responses": {"200": {"description": "ok"}}
+      },
+      "put": {
+        "operationId": "getPetsPetId",
+        "responses": {"200": {"description": "ok"}}
+      }
+    }
+  }
+}`
+
+func TestValidateSwagger(t *testing.T) {
+	fixed, problems, err := ValidateSwagger([]byte(validateSwagger), "pets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"line 6: warning: paths./pets/{petId}.get: no operationId, using getPetsPetId2",
+		"line 8: warning: paths./pets/{petId}.get.parameters.0: parameter petId has no type, using string",
+		"line 13: warning: paths./pets/{petId}.get.parameters.1: array parameter tags has no items, using strings",
+		"line 22: warning: paths./pets/{petId}.delete.operationId: operationId getPetsPetId is already used, using deletePetsPetId",
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+		if !p.Warning || !p.Fixed {
+			t.Errorf("expecting a fixed warning: %s", p)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got the problems\n%s\nexpecting\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	var doc map[string]interface{}
+	if err = json.Unmarshal(fixed, &doc); err != nil {
+		t.Fatal(err)
+	}
+	pathItem := doc["paths"].(map[string]interface{})["/pets/{petId}"].(map[string]interface{})
+	ids := make(map[string]string)
+	for _, method := range []string{"get", "delete", "put"} {
+		ids[method], _ = pathItem[method].(map[string]interface{})["operationId"].(string)
+	}
+	if !reflect.DeepEqual(ids, map[string]string{"get": "getPetsPetId2", "delete": "deletePetsPetId", "put": "getPetsPetId"}) {
+		t.Errorf("got the operationIds %v", ids)
+	}
+	params := pathItem["get"].(map[string]interface{})["parameters"].([]interface{})
+	if paramType := params[0].(map[string]interface{})["type"]; paramType != "string" {
+		t.Errorf("got the type %v for petId, expecting string", paramType)
+	}
+	if items := params[1].(map[string]interface{})["items"]; !reflect.DeepEqual(items, map[string]interface{}{"type": "string"}) {
+		t.Errorf("got the items %v for tags", items)
+	}
+}
+
+func TestValidateSwaggerErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		swagger string
+		problem string
+	}{
+		{"openapi 3", `{"openapi": "3.0.0", "paths": {}}`, "error: swagger: only swagger 2.0 is supported"},
+		{"missing definition", `{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "getPets",
+        "responses": {"200": {"description": "ok", "schema": {"$ref": "#/definitions/Pet"}}}
+      }
+    }
+  }
+}`, "line 7: error: paths./pets.get.responses.200.schema.$ref: #/definitions/Pet doesn't exist"},
+		{"body without schema", `{
+  "swagger": "2.0",
+  "paths": {
+    "/pets": {
+      "post": {
+        "operationId": "addPet",
+        "parameters": [{"name": "pet", "in": "body"}]
+      }
+    }
+  }
+}`, "line 7: error: paths./pets.post.parameters.0: body parameter pet has no schema"},
+		{"unknown type", `{
+  "swagger": "2.0",
+  "parameters": {
+    "limit": {
+      "name": "limit",
+      "in": "query",
+      "type": "int"
+    }
+  },
+  "paths": {}
+}`, "line 7: error: parameters.limit.type: parameter limit has the unknown type int"},
+	}
+	for _, c := range cases {
+		fixed, problems, err := ValidateSwagger([]byte(c.swagger), "pets.json")
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err.Error())
+			continue
+		}
+		if len(problems) != 1 || problems[0].String() != c.problem || problems[0].Warning {
+			t.Errorf("%s: got the problems %v, expecting %s", c.name, problems, c.problem)
+		}
+		if string(fixed) != c.swagger {
+			t.Errorf("%s: the swagger changed without a fix", c.name)
+		}
+	}
+
+	if _, _, err := ValidateSwagger([]byte(`{"swagger": `), "pets.json"); err == nil {
+		t.Errorf("expecting an error for invalid json")
+	}
+}
@@ -1,14 +1,107 @@
 package mqutil
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+// The log levels, from the most to the least verbose.
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// The log formats. Text is the human readable default, json has one object per line for log ingestion.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// ParseLogLevel returns the level of the name, debug, info, warn or error.
+func ParseLogLevel(name string) (int, error) {
+	for level, levelName := range levelNames {
+		if strings.ToLower(name) == levelName {
+			return level, nil
+		}
+	}
+	return LevelInfo, NewError(ErrInvalid, fmt.Sprintf("unknown log level %s, expecting debug, info, warn or error", name))
+}
+
+// CheckLogFormat returns an error if the log format isn't one we support.
+func CheckLogFormat(format string) error {
+	if format == LogFormatText || format == LogFormatJSON {
+		return nil
+	}
+	return NewError(ErrInvalid, fmt.Sprintf("unknown log format %s, expecting text or json", format))
+}
+
+// logWriter is the output of the Logger. In json format each line becomes an object with the level and
+// the suite and test being run.
+type logWriter struct {
+	out   io.Writer
+	suite string
+	test  string
+	mutex sync.Mutex
+}
+
+type logEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Suite     string `json:"suite,omitempty"`
+	Test      string `json:"test,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Write writes a line of the Logger, which is at info level.
+func (w *logWriter) Write(p []byte) (int, error) {
+	if LevelInfo < LogLevel {
+		return len(p), nil
+	}
+	if LogFormat != LogFormatJSON {
+		return w.out.Write(p)
+	}
+	if err := w.writeEntry(LevelInfo, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) writeEntry(level int, message string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	line, err := json.Marshal(&logEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     levelNames[level],
+		Suite:     w.suite,
+		Test:      w.test,
+		Message:   strings.TrimSuffix(message, "\n"),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(append(line, '\n'))
+	return err
+}
+
 func NewLogger(out io.Writer) *log.Logger {
-	Logger = log.New(out, "", (log.Ldate | log.Lmicroseconds | log.Lshortfile))
+	logOutput = &logWriter{out: out}
+	if LogFormat == LogFormatJSON {
+		// The entries have their own timestamp.
+		Logger = log.New(logOutput, "", 0)
+	} else {
+		Logger = log.New(logOutput, "", (log.Ldate | log.Lmicroseconds | log.Lshortfile))
+	}
+	leveledLogger = log.New(out, "", (log.Ldate | log.Lmicroseconds | log.Lshortfile))
 	return Logger
 }
 
@@ -25,8 +118,65 @@ func NewFileLogger(path string) *log.Logger {
 	return NewLogger(f)
 }
 
+// SetLogContext sets the suite and test of the json log entries that follow. With parallel suites, the
+// entries logged between two tests may carry the context of another suite.
+func SetLogContext(suite string, test string) {
+	if logOutput == nil {
+		return
+	}
+	logOutput.mutex.Lock()
+	defer logOutput.mutex.Unlock()
+	logOutput.suite = suite
+	logOutput.test = test
+}
+
+// IsDebug returns whether the debug output is on, the request and response dumps on the console and in the log.
+func IsDebug() bool {
+	return LogLevel <= LevelDebug
+}
+
+func logAt(level int, format string, args ...interface{}) {
+	if level < LogLevel || Logger == nil || logOutput == nil {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	if LogFormat == LogFormatJSON {
+		logOutput.writeEntry(level, message)
+		return
+	}
+	if level != LevelInfo {
+		message = strings.ToUpper(levelNames[level]) + " " + message
+	}
+	// Not through the Logger, which drops the lines when the level is above info.
+	leveledLogger.Output(3, message)
+}
+
+// Debugf logs at debug level, only written with -v.
+func Debugf(format string, args ...interface{}) {
+	logAt(LevelDebug, format, args...)
+}
+
+// Infof logs at info level, the same as Logger.Printf.
+func Infof(format string, args ...interface{}) {
+	logAt(LevelInfo, format, args...)
+}
+
+// Warnf logs at warn level.
+func Warnf(format string, args ...interface{}) {
+	logAt(LevelWarn, format, args...)
+}
+
+// Errorf logs at error level.
+func Errorf(format string, args ...interface{}) {
+	logAt(LevelError, format, args...)
+}
+
 // There is only one logger per process.
 var Logger *log.Logger
 
-// Whether verbose mose is on
-var Verbose bool
+var logOutput *logWriter
+var leveledLogger *log.Logger // the text lines of Debugf, Warnf and so on
+
+// The lowest level that is logged, and the format of the log. Set them before creating the Logger.
+var LogLevel = LevelInfo
+var LogFormat = LogFormatText
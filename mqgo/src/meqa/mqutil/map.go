@@ -162,7 +162,7 @@ func ArrayCopy(src []interface{}) (dst []interface{}) {
 
 func InterfacePrint(m interface{}, printToConsole bool) {
 	yamlBytes, _ := yaml.Marshal(m)
-	Logger.Print(string(yamlBytes))
+	Debugf("%s", string(yamlBytes))
 	if printToConsole {
 		fmt.Println(string(yamlBytes))
 	}
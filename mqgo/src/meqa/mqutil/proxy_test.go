@@ -0,0 +1,86 @@
+package mqutil
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestBypassProxy(t *testing.T) {
+	cases := []struct {
+		host    string
+		noProxy string
+		bypass  bool
+	}{
+		{"example.com", "", false},
+		{"example.com", "*", true},
+		{"example.com:8080", "other.com, *", true},
+		{"example.com", "example.com", true},
+		{"EXAMPLE.com", "Example.COM", true},
+		{"api.example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com", ".example.com", true},
+		{"api.example.com", ".example.com", true},
+		{"badexample.com", ".example.com", false},
+		{"api.example.com", "*.example.com", true},
+		{"example.com", "*.example.com", true},
+		{"example.com:8080", "example.com", true},
+		{"example.com:8080", "example.com:8080", true},
+		{"example.com:9090", "example.com:8080", false},
+		{"example.com", "example.com:8080", false},
+		{"10.1.2.3:80", "10.0.0.0/8", true},
+		{"11.1.2.3", "10.0.0.0/8", false},
+		{"example.com", "10.0.0.0/8", false},
+		{"10.1.2.3", "10.1.2.3", true},
+		{"[::1]:8080", "::1", true},
+		{"[::1]:8080", "[::1]:8080", true},
+		{"[::1]:8080", "::1/128", true},
+		{"example.com", " , ,other.com , example.com ", true},
+		{"example.com", "other.com,another.com", false},
+	}
+	for _, c := range cases {
+		if bypass := bypassProxy(c.host, c.noProxy); bypass != c.bypass {
+			t.Errorf("bypassProxy(%s, %q) = %t, expecting %t", c.host, c.noProxy, bypass, c.bypass)
+		}
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	noProxy, lowerNoProxy := os.Getenv("NO_PROXY"), os.Getenv("no_proxy")
+	defer os.Setenv("NO_PROXY", noProxy)
+	defer os.Setenv("no_proxy", lowerNoProxy)
+	os.Setenv("NO_PROXY", "internal.example.com")
+	os.Unsetenv("no_proxy")
+
+	proxy, err := ProxyFunc("user:p%40ss@proxy.example.com:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://api.example.com/pets", nil)
+	proxyURL, err := proxy(req)
+	if err != nil || proxyURL == nil {
+		t.Fatalf("expecting the proxy, got %v, %v", proxyURL, err)
+	}
+	if proxyURL.Scheme != "http" || proxyURL.Host != "proxy.example.com:8080" {
+		t.Errorf("got the proxy %s", proxyURL)
+	}
+	// The transport sends the credentials of the url as the Proxy-Authorization.
+	password, _ := proxyURL.User.Password()
+	if proxyURL.User.Username() != "user" || password != "p@ss" {
+		t.Errorf("got the credentials %s", proxyURL.User)
+	}
+
+	req, _ = http.NewRequest("GET", "http://internal.example.com:8000/pets", nil)
+	if proxyURL, err = proxy(req); err != nil || proxyURL != nil {
+		t.Errorf("expecting NO_PROXY to bypass the proxy, got %v, %v", proxyURL, err)
+	}
+
+	if _, err = ProxyFunc("https://proxy.example.com"); err != nil {
+		t.Errorf("expecting an https proxy to be valid: %s", err.Error())
+	}
+	for _, bad := range []string{"ftp://proxy.example.com", "http://", "http://proxy:port"} {
+		if _, err = ProxyFunc(bad); err == nil {
+			t.Errorf("expecting the proxy %s to be invalid", bad)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mqutil
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out the calls so that there are at most rps of them per second, without bursts.
+// It's safe for concurrent use, so one limiter can be shared by all the suites and parallel workers.
+type RateLimiter struct {
+	interval time.Duration
+	next     time.Time // when the next call may go
+	mutex    sync.Mutex
+}
+
+// NewRateLimiter returns a limiter of rps calls per second.
+func NewRateLimiter(rps float64) *RateLimiter {
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// Wait blocks until the caller may make its call.
+func (l *RateLimiter) Wait() {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Pause holds all the calls for the duration, e.g. when the server asked us to back off.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
@@ -0,0 +1,61 @@
+package mqutil
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(100)
+	if l.interval != 10*time.Millisecond {
+		t.Errorf("got the interval %v for 100 rps", l.interval)
+	}
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		l.Wait()
+	}
+	// The first call goes right away, the others are spaced out.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("6 calls at 100 rps took %v, expecting at least 50ms", elapsed)
+	}
+
+	// The calls are spaced out across the goroutines.
+	l = NewRateLimiter(200)
+	start = time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				l.Wait()
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("12 concurrent calls at 200 rps took %v, expecting at least 55ms", elapsed)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l := NewRateLimiter(1000)
+	l.Wait()
+	l.Pause(50 * time.Millisecond)
+	start := time.Now()
+	l.Wait()
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("the call after a 50ms pause went after %v", elapsed)
+	}
+
+	// A pause shorter than the wait for the next call doesn't bring it forward.
+	l = NewRateLimiter(10)
+	l.Wait()
+	l.Pause(time.Millisecond)
+	start = time.Now()
+	l.Wait()
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("the call after a short pause went after %v, expecting the 100ms interval", elapsed)
+	}
+}